@@ -0,0 +1,346 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/firebase"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/validation"
+
+	"cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/auth"
+	"github.com/gorilla/mux"
+)
+
+// GET /admin/users?q=alice@&role=admin&limit=50&cursor={uid}
+// q is an email prefix. Combining q with role requires a composite index on (role, email).
+func ListUsers(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	q := r.URL.Query()
+	logger.LogInfof("ListUsers called by uid: %s with filters %v", uid, q)
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+
+	limit := 50
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 200 {
+			apperror.Write(w, r, apperror.Validation("Invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	users := firestoreClient.Collection("users")
+	query := users.Query
+	if role := q.Get("role"); role != "" {
+		query = query.Where("role", "==", role)
+	}
+	if search := q.Get("q"); search != "" {
+		query = query.Where("email", ">=", search).Where("email", "<", search+"\uf8ff").OrderBy("email", firestore.Asc)
+	} else {
+		query = query.OrderBy(firestore.DocumentID, firestore.Asc)
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		snap, err := users.Doc(cursor).Get(ctx)
+		if apperror.IsNotFound(err) {
+			apperror.Write(w, r, apperror.Validation("Invalid cursor"))
+			return
+		}
+		if err != nil {
+			apperror.Write(w, r, apperror.Internal("Failed to fetch users", err))
+			return
+		}
+		query = query.StartAfter(snap)
+	}
+
+	// Fetch one extra document to know whether there is a next page
+	docs, err := query.Limit(limit + 1).Documents(ctx).GetAll()
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to list users: %v", err)
+		logger.LogFirestoreOperation("READ", "users", "", false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to list users", err))
+		return
+	}
+
+	nextCursor := ""
+	if len(docs) > limit {
+		docs = docs[:limit]
+		nextCursor = docs[limit-1].Ref.ID
+	}
+	result := make([]dto.UserResponse, 0, len(docs))
+	for _, doc := range docs {
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			logger.LogErrorf("Error decoding user %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		user.ID = doc.Ref.ID
+		result = append(result, dto.NewUserResponse(user))
+	}
+
+	logger.LogFirestoreOperation("READ", "users", "", true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.UserListResponse{Users: result, Count: len(result), NextCursor: nextCursor})
+}
+
+// GET /admin/users/{uid}
+func GetUserDetails(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	targetUID := mux.Vars(r)["uid"]
+	logger.LogInfof("GetUserDetails called for uid: %s by uid: %s", targetUID, uid)
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+
+	user, err := getUser(ctx, targetUID, "")
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	docs, err := firestoreClient.Collection("pets").Where("ownerId", "==", targetUID).Documents(ctx).GetAll()
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to fetch pets of %s: %v", targetUID, err)
+		logger.LogFirestoreOperation("READ", "pets", "", false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to fetch pets", err))
+		return
+	}
+
+	resp := dto.AdminUserResponse{
+		User:  dto.NewUserResponse(user),
+		Pets:  []dto.PetResponse{},
+		Stays: []dto.StayResponse{},
+	}
+	for _, doc := range docs {
+		var pet models.Pet
+		if err := doc.DataTo(&pet); err != nil {
+			logger.LogErrorf("Error decoding pet %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		pet.PetID = doc.Ref.ID
+		resp.Pets = append(resp.Pets, dto.NewPetResponse(pet))
+		if !pet.CheckIn.IsZero() {
+			resp.Stays = append(resp.Stays, dto.NewStayResponse(pet))
+		}
+	}
+
+	logger.LogFirestoreOperation("READ", "pets", "", true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// PUT /admin/users/{uid}/role
+// Also sets the role and branch token claims; the user's clients are told to refresh their token.
+func ChangeUserRole(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	targetUID := mux.Vars(r)["uid"]
+	logger.LogInfof("ChangeUserRole called for uid: %s by uid: %s", targetUID, uid)
+
+	req := new(dto.ChangeRoleRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode role change: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+	if targetUID == uid {
+		apperror.Write(w, r, apperror.Conflict("Admins cannot change their own role"))
+		return
+	}
+
+	var oldRole, oldBranch string
+	userRef := firestoreClient.Collection("users").Doc(targetUID)
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return err
+		}
+		oldRole, _ = doc.Data()["role"].(string)
+		oldBranch, _ = doc.Data()["branch"].(string)
+		return tx.Update(userRef, []firestore.Update{
+			{Path: "role", Value: req.Role},
+			{Path: "branch", Value: req.Branch},
+		})
+	})
+	duration := time.Since(start)
+	if apperror.IsNotFound(err) {
+		apperror.Write(w, r, apperror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to change role of %s: %v", targetUID, err)
+		logger.LogFirestoreOperation("UPDATE", "users", targetUID, false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to change role", err))
+		return
+	}
+	logger.LogFirestoreOperation("UPDATE", "users", targetUID, true, duration)
+
+	// Firestore stays the source of truth, so a failed claim sync is reported but not rolled back
+	if err := syncClaims(ctx, targetUID); err != nil {
+		logger.LogErrorf("Failed to sync claims of %s, retrying in the background: %v", targetUID, err)
+		enqueueJob(ctx, JobSyncClaims, userPayload{UID: targetUID})
+	}
+
+	audit(r, "user.role_changed", "user", targetUID,
+		map[string]interface{}{"role": oldRole, "branch": oldBranch},
+		map[string]interface{}{"role": req.Role, "branch": req.Branch})
+
+	logger.LogInfof("Role of %s changed from %q to %q", targetUID, oldRole, req.Role)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, time.Since(start))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /admin/users/{uid}/disable
+func DisableUser(w http.ResponseWriter, r *http.Request) {
+	setUserDisabled(w, r, true)
+}
+
+// POST /admin/users/{uid}/enable
+func EnableUser(w http.ResponseWriter, r *http.Request) {
+	setUserDisabled(w, r, false)
+}
+
+func setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	targetUID := mux.Vars(r)["uid"]
+	logger.LogInfof("setUserDisabled(%t) called for uid: %s by uid: %s", disabled, targetUID, uid)
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+	if targetUID == uid {
+		apperror.Write(w, r, apperror.Conflict("Admins cannot disable their own account"))
+		return
+	}
+
+	user, err := getUser(ctx, targetUID, "")
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	client, err := firebase.App.Auth(ctx)
+	if err != nil {
+		logger.LogErrorf("Failed to get auth client: %v", err)
+		apperror.Write(w, r, apperror.Internal("Failed to get auth client", err))
+		return
+	}
+	if _, err := client.UpdateUser(ctx, targetUID, (&auth.UserToUpdate{}).Disabled(disabled)); err != nil {
+		logger.LogErrorf("Failed to update auth user %s: %v", targetUID, err)
+		apperror.Write(w, r, apperror.Internal("Failed to update account", err))
+		return
+	}
+	if disabled {
+		if err := client.RevokeRefreshTokens(ctx, targetUID); err != nil {
+			logger.LogWarningf("Failed to revoke refresh tokens of %s: %v", targetUID, err)
+		}
+	}
+	middleware.ForgetAccountState(targetUID)
+
+	_, err = firestoreClient.Collection("users").Doc(targetUID).Update(ctx, []firestore.Update{{Path: "disabled", Value: disabled}})
+	if err != nil {
+		logger.LogErrorf("Failed to store disabled state of %s: %v", targetUID, err)
+		logger.LogFirestoreOperation("UPDATE", "users", targetUID, false, time.Since(start))
+		apperror.Write(w, r, apperror.Internal("Failed to update account", err))
+		return
+	}
+	logger.LogFirestoreOperation("UPDATE", "users", targetUID, true, time.Since(start))
+
+	action := "user.enabled"
+	if disabled {
+		action = "user.disabled"
+	}
+	logger.LogAuthOperation(action, targetUID, true)
+	audit(r, action, "user", targetUID,
+		map[string]interface{}{"disabled": user.Disabled},
+		map[string]interface{}{"disabled": disabled})
+
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, time.Since(start))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// syncClaims copies the stored role and branch of the user into their Firebase custom claims,
+// keeping other claims. Tokens only pick up new claims when refreshed, so it also bumps
+// user_claims/{uid}.refreshTime; clients listen to that document and call getIdToken(true).
+// Demoted admins cannot be trusted to refresh, so their refresh tokens are revoked instead.
+func syncClaims(ctx context.Context, uid string) error {
+	doc, err := firestoreClient.Collection("users").Doc(uid).Get(ctx)
+	if err != nil {
+		return err
+	}
+	role, _ := doc.Data()["role"].(string)
+	branch, _ := doc.Data()["branch"].(string)
+
+	client, err := firebase.App.Auth(ctx)
+	if err != nil {
+		return err
+	}
+	record, err := client.GetUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+	claims := make(map[string]interface{}, len(record.CustomClaims)+2)
+	for k, v := range record.CustomClaims {
+		claims[k] = v
+	}
+	currentRole, _ := claims[middleware.ClaimRole].(string)
+	currentBranch, _ := claims[middleware.ClaimBranch].(string)
+	if currentRole == role && currentBranch == branch {
+		return nil // already in sync
+	}
+	// Existing tokens keep the old claims until they expire, so a demoted admin's sessions
+	// are revoked; this happens first so that a retry after a failure revokes them again
+	if currentRole == RoleAdmin && (role != RoleAdmin || branch != currentBranch) {
+		if err := client.RevokeRefreshTokens(ctx, uid); err != nil {
+			return err
+		}
+		middleware.ForgetAccountState(uid)
+		logger.LogInfof("Revoked sessions of %s after losing the %s role", uid, currentRole)
+	}
+	claims[middleware.ClaimRole] = role
+	if branch != "" {
+		claims[middleware.ClaimBranch] = branch
+	} else {
+		delete(claims, middleware.ClaimBranch)
+	}
+	if err := client.SetCustomUserClaims(ctx, uid, claims); err != nil {
+		return err
+	}
+
+	_, err = firestoreClient.Collection("user_claims").Doc(uid).Set(ctx, map[string]interface{}{
+		"refreshTime": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	logger.LogInfof("Claims of %s synced (role=%q, branch=%q)", uid, role, branch)
+	return nil
+}
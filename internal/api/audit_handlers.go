@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+)
+
+var auditService *services.AuditService
+
+// maxAuditExport caps the number of entries a single export returns
+const maxAuditExport = 50000
+
+// SetAuditService sets the audit service for the handlers
+func SetAuditService(as *services.AuditService) {
+	auditService = as
+}
+
+// audit records a change made by the caller. before and after are the resource before and
+// after the change (nil for creations and deletions); only the fields that differ are stored.
+// Failures are logged but do not fail the request, since the change has already been applied.
+// Audited routes must require a token; entries without an actor are refused.
+func audit(r *http.Request, action, resourceType, resourceID string, before, after interface{}) {
+	if auditService == nil {
+		logger.LogWarningf("Audit service not initialized, dropping %s audit entry", action)
+		return
+	}
+	uid, _ := r.Context().Value("uid").(string)
+	if uid == "" {
+		logger.LogErrorf("Refusing %s audit entry for %s/%s without an actor", action, resourceType, resourceID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+
+	role, err := callerRole(ctx, r)
+	if err != nil {
+		logger.LogWarningf("Failed to resolve role of %s for audit: %v", uid, err)
+	}
+
+	beforeMap, afterMap := auditDiff(toAuditMap(before), toAuditMap(after))
+	err = auditService.Record(ctx, models.AuditEntry{
+		ActorUID:     uid,
+		ActorRole:    role,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       beforeMap,
+		After:        afterMap,
+		RequestID:    logger.RequestIDFromContext(r.Context()),
+		IP:           clientIP(r),
+	})
+	if err != nil {
+		logger.LogErrorf("Failed to record %s audit entry for %s/%s: %v", action, resourceType, resourceID, err)
+	}
+}
+
+// toAuditMap converts a response DTO or map to a generic map using its JSON field names
+func toAuditMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.LogWarningf("Failed to encode audit value: %v", err)
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// auditDiff keeps only the fields that changed when both sides are known
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	b := map[string]interface{}{}
+	a := map[string]interface{}{}
+	for k, v := range before {
+		if !reflect.DeepEqual(v, after[k]) {
+			b[k] = v
+			a[k] = after[k]
+		}
+	}
+	for k, v := range after {
+		if _, ok := before[k]; !ok {
+			b[k] = nil
+			a[k] = v
+		}
+	}
+	return b, a
+}
+
+// redactToken identifies a device token in the audit log without storing the token itself
+func redactToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// clientIP returns the caller's IP, honoring X-Forwarded-For from trusted proxies
+func clientIP(r *http.Request) string {
+	return middleware.ClientIP(r)
+}
+
+// auditFilterFromQuery parses the audit filters shared by listing and export
+func auditFilterFromQuery(r *http.Request) (services.AuditFilter, error) {
+	q := r.URL.Query()
+	f := services.AuditFilter{
+		ActorUID:     q.Get("actor"),
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resourceType"),
+		ResourceID:   q.Get("resourceId"),
+	}
+	var fields []apperror.FieldError
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			fields = append(fields, apperror.FieldError{Field: "from", Message: "must be an RFC 3339 timestamp"})
+		}
+		f.From = t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			fields = append(fields, apperror.FieldError{Field: "to", Message: "must be an RFC 3339 timestamp"})
+		}
+		f.To = t
+	}
+	if len(fields) > 0 {
+		return f, apperror.Validation("Invalid filter", fields...)
+	}
+	return f, nil
+}
+
+// GET /admin/audit?actor=&action=&resourceType=&resourceId=&from=&to=&limit=50&cursor=
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	logger.LogInfof("GetAuditLog called with filters %v", r.URL.Query())
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+	if auditService == nil {
+		apperror.Write(w, r, apperror.Internal("Audit service not available", nil))
+		return
+	}
+
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 500 {
+			apperror.Write(w, r, apperror.Validation("Invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	entries, next, err := auditService.List(ctx, filter, limit, r.URL.Query().Get("cursor"))
+	duration := time.Since(start)
+	if apperror.IsNotFound(err) {
+		apperror.Write(w, r, apperror.Validation("Invalid cursor"))
+		return
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to list audit entries: %v", err)
+		logger.LogFirestoreOperation("READ", "audit_logs", "", false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to list audit entries", err))
+		return
+	}
+
+	resp := make([]dto.AuditEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		resp = append(resp, dto.NewAuditEntryResponse(e))
+	}
+	logger.LogFirestoreOperation("READ", "audit_logs", "", true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.AuditListResponse{Entries: resp, Count: len(resp), NextCursor: next})
+}
+
+// auditExportFailed is the id column of the row ending a CSV export that failed midway
+const auditExportFailed = "#export-failed"
+
+// GET /admin/audit/export?format=csv|json&<same filters as /admin/audit>
+// Streams up to maxAuditExport entries as a download.
+func ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	logger.LogInfof("ExportAuditLog called with format=%s", format)
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+	if auditService == nil {
+		apperror.Write(w, r, apperror.Internal("Audit service not available", nil))
+		return
+	}
+	if format != "json" && format != "csv" {
+		apperror.Write(w, r, apperror.Validation("format must be csv or json"))
+		return
+	}
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	filename := "audit-" + time.Now().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Trailer", "X-Export-Status")
+
+	// Headers are sent with the first entry, so a later failure cannot change the status code.
+	// It is reported in the X-Export-Status trailer, and the body is left visibly incomplete:
+	// a JSON array is not closed and a CSV file ends with an error row.
+	count := 0
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "timestamp", "actorUid", "actorRole", "action", "resourceType", "resourceId", "before", "after", "requestId", "ip"})
+		err = auditService.Each(ctx, filter, maxAuditExport, func(e models.AuditEntry) error {
+			before, _ := json.Marshal(e.Before)
+			after, _ := json.Marshal(e.After)
+			count++
+			return cw.Write([]string{e.ID, dto.FormatTime(e.Timestamp), e.ActorUID, e.ActorRole, e.Action,
+				e.ResourceType, e.ResourceID, string(before), string(after), e.RequestID, e.IP})
+		})
+		if err != nil {
+			cw.Write([]string{auditExportFailed, "export failed after " + strconv.Itoa(count) + " entries"})
+		}
+		cw.Flush()
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("["))
+		enc := json.NewEncoder(w)
+		err = auditService.Each(ctx, filter, maxAuditExport, func(e models.AuditEntry) error {
+			if count > 0 {
+				w.Write([]byte(","))
+			}
+			count++
+			return enc.Encode(dto.NewAuditEntryResponse(e))
+		})
+		if err == nil {
+			w.Write([]byte("]\n"))
+		}
+	}
+	if err != nil {
+		w.Header().Set("X-Export-Status", "failed")
+		logger.LogErrorf("Audit export failed after %d entries: %v", count, err)
+		return
+	}
+	w.Header().Set("X-Export-Status", "complete")
+
+	logger.LogInfof("Exported %d audit entries as %s", count, format)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+}
@@ -2,12 +2,17 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"slices"
 	"time"
 
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
 	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/validation"
 
 	"cloud.google.com/go/firestore"
 	"github.com/gorilla/mux"
@@ -15,124 +20,177 @@ import (
 
 func ChatRoutes(r *mux.Router) {
 	chats := r.PathPrefix("/chats").Subrouter()
-	chats.HandleFunc("", CreateChatRoom).Methods("POST")
-	chats.HandleFunc("/{roomId}/messages", SendMessage).Methods("POST")
-	chats.HandleFunc("/{roomId}/messages", GetMessages).Methods("GET")
+	chats.Handle("", middleware.VerifyToken(http.HandlerFunc(CreateChatRoom))).Methods("POST")
+	chats.Handle("/{roomId}/messages", middleware.VerifyToken(idempotent(http.HandlerFunc(SendMessage)))).Methods("POST")
+	chats.Handle("/{roomId}/messages", middleware.VerifyToken(http.HandlerFunc(GetMessages))).Methods("GET")
 }
 
-// POST /chats/{roomId}
+// errNotParticipant is returned to callers who are not in the chat room
+var errNotParticipant = apperror.Forbidden("You are not a participant of this chat room")
+
+// decodeChatRoom decodes a chat room document, reporting a missing room as not found
+func decodeChatRoom(doc *firestore.DocumentSnapshot, err error) (models.ChatRoom, error) {
+	var room models.ChatRoom
+	if apperror.IsNotFound(err) {
+		return room, apperror.NotFound("Chat room not found")
+	}
+	if err != nil {
+		return room, err
+	}
+	if err := doc.DataTo(&room); err != nil {
+		return room, err
+	}
+	room.ID = doc.Ref.ID
+	return room, nil
+}
+
+// POST /chats
 func CreateChatRoom(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	roomId := mux.Vars(r)["roomId"]
-	logger.LogInfof("CreateChatRoom called for roomId: %s", roomId)
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("CreateChatRoom called by uid: %s", uid)
 
-	room := new(models.ChatRoom)
-	if err := json.NewDecoder(r.Body).Decode(room); err != nil {
-		logger.LogErrorf("Failed to decode chat room: %v", err)
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	req := new(dto.CreateChatRoomRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode chat room: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+	if !slices.Contains(req.UserIDs, uid) {
+		apperror.Write(w, r, apperror.Forbidden("You can only create chat rooms you take part in"))
 		return
 	}
-	room.CreatedAt = time.Now()
+	// The room ID is derived from the participants, so the same users always share one room
+	room := req.ToModel(time.Now())
+	roomId := room.ID
 
 	// Check if chat room already exists
 	docRef := firestoreClient.Collection("chats").Doc(roomId)
-	docSnap, err := docRef.Get(context.Background())
+	docSnap, err := docRef.Get(r.Context())
 	if err == nil && docSnap.Exists() {
 		logger.LogInfof("Chat room already exists: %s", roomId)
-		room.ID = roomId
+		existing := new(models.ChatRoom)
+		if err := docSnap.DataTo(existing); err != nil {
+			logger.LogErrorf("Error decoding chat room: %v", err)
+			apperror.Write(w, r, apperror.Internal("Error processing chat room", err))
+			return
+		}
+		existing.ID = roomId
 		logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
-		json.NewEncoder(w).Encode(room)
+		writeJSON(w, r, http.StatusOK, dto.NewChatRoomResponse(*existing))
 		return
 	}
 
-	_, err = docRef.Set(context.Background(), room)
+	_, err = docRef.Set(r.Context(), room)
 	duration := time.Since(start)
 	if err != nil {
 		logger.LogErrorf("Failed to create chat room: %v", err)
 		logger.LogFirestoreOperation("CREATE", "chats", roomId, false, duration)
-		http.Error(w, "Error creating chat room", http.StatusInternalServerError)
+		apperror.Write(w, r, apperror.Internal("Error creating chat room", err))
 		return
 	}
-	room.ID = roomId
+	audit(r, "chat.created", "chat", roomId, nil, dto.NewChatRoomResponse(room))
 	logger.LogInfof("Chat room created: %s", roomId)
 	logger.LogFirestoreOperation("CREATE", "chats", roomId, true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
-	json.NewEncoder(w).Encode(room)
+	writeJSON(w, r, http.StatusOK, dto.NewChatRoomResponse(room))
 }
 
 // POST /chats/{roomId}/messages
 func SendMessage(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	roomId := mux.Vars(r)["roomId"]
+	uid, _ := r.Context().Value("uid").(string)
 	logger.LogInfof("SendMessage called for roomId: %s", roomId)
 
-	msg := new(models.Message)
-	if err := json.NewDecoder(r.Body).Decode(msg); err != nil {
-		logger.LogErrorf("Failed to decode message: %v", err)
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	req := new(dto.SendMessageRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode message: %v", err)
+		apperror.Write(w, r, err)
 		return
 	}
-	msg.Timestamp = time.Now()
-	msg.RoomID = roomId
-
-	doc, _, err := firestoreClient.Collection("chats").Doc(roomId).Collection("messages").Add(context.Background(), msg)
+	// The sender is always the authenticated caller
+	msg := req.ToModel(roomId, uid, time.Now())
+
+	// Only participants of an existing room can post to it
+	roomRef := firestoreClient.Collection("chats").Doc(roomId)
+	msgRef := roomRef.Collection("messages").NewDoc()
+	err := firestoreClient.RunTransaction(r.Context(), func(ctx context.Context, tx *firestore.Transaction) error {
+		room, err := decodeChatRoom(tx.Get(roomRef))
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(room.UserIDs, uid) {
+			return errNotParticipant
+		}
+		return tx.Create(msgRef, msg)
+	})
 	duration := time.Since(start)
 	if err != nil {
 		logger.LogErrorf("Failed to send message: %v", err)
 		logger.LogFirestoreOperation("CREATE", "chats/"+roomId+"/messages", "", false, duration)
-		http.Error(w, "Error sending message", http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
-	msg.ID = doc.ID
+	msg.ID = msgRef.ID
+	// Message content is deliberately kept out of the audit log
+	audit(r, "message.sent", "message", msg.ID, nil, map[string]interface{}{"roomId": roomId})
 	logger.LogInfof("Message sent with ID: %s in roomId: %s", msg.ID, roomId)
+	enqueueJob(r.Context(), JobNotifyChat, notifyChatPayload{
+		RoomID:   roomId,
+		SenderID: msg.SenderID,
+		Notification: services.Notification{
+			Type:  services.NotificationChatMessage,
+			Title: "New message",
+			Body:  msg.Content,
+			Data:  map[string]string{"roomId": roomId, "messageId": msg.ID},
+		},
+	})
 	logger.LogFirestoreOperation("CREATE", "chats/"+roomId+"/messages", msg.ID, true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
-	json.NewEncoder(w).Encode(msg)
-}
-
-type MessageResponse struct {
-	ID        string `json:"id"`
-	Content   string `json:"content"`
-	SenderID  string `json:"senderId"`
-	RoomID    string `json:"roomId"`
-	Timestamp string `json:"timestamp"`
+	writeJSON(w, r, http.StatusOK, dto.NewMessageResponse(msg))
 }
 
 // GET /chats/{roomId}/messages
+// Only participants of the room can read its messages.
 func GetMessages(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
 	roomId := mux.Vars(r)["roomId"]
-	logger.LogInfof("GetMessages called for roomId: %s", roomId)
+	logger.LogInfof("GetMessages called for roomId: %s by uid: %s", roomId, uid)
+
+	roomRef := firestoreClient.Collection("chats").Doc(roomId)
+	room, err := decodeChatRoom(roomRef.Get(r.Context()))
+	if err != nil {
+		logger.LogWarningf("Failed to get chat room %s: %v", roomId, err)
+		apperror.Write(w, r, err)
+		return
+	}
+	if !slices.Contains(room.UserIDs, uid) {
+		logger.LogWarningf("User %s denied access to chat room %s", uid, roomId)
+		apperror.Write(w, r, errNotParticipant)
+		return
+	}
 
-	docs, err := firestoreClient.Collection("chats").Doc(roomId).Collection("messages").OrderBy("timestamp", firestore.Asc).Documents(context.Background()).GetAll()
+	docs, err := roomRef.Collection("messages").OrderBy("timestamp", firestore.Asc).Documents(r.Context()).GetAll()
 	duration := time.Since(start)
 	if err != nil {
 		logger.LogErrorf("Failed to fetch messages: %v", err)
 		logger.LogFirestoreOperation("READ", "chats/"+roomId+"/messages", "", false, duration)
-		http.Error(w, "Failed to fetch messages", http.StatusInternalServerError)
+		apperror.Write(w, r, apperror.Internal("Failed to fetch messages", err))
 		return
 	}
 
-	loc, _ := time.LoadLocation("Asia/Jakarta") // UTC+7
-
-	var messages []MessageResponse
+	messages := []dto.MessageResponse{}
 	for _, doc := range docs {
 		m := new(models.Message)
 		doc.DataTo(m)
 		m.ID = doc.Ref.ID
-		// Convert timestamp to UTC+7 before formatting
-		messages = append(messages, MessageResponse{
-			ID:        m.ID,
-			Content:   m.Content,
-			SenderID:  m.SenderID,
-			RoomID:    m.RoomID,
-			Timestamp: m.Timestamp.In(loc).Format(time.RFC3339),
-		})
+		messages = append(messages, dto.NewMessageResponse(*m))
 		logger.LogDebugf("Message: %+v", m)
 	}
 	logger.LogInfof("Fetched %d messages for roomId: %s", len(messages), roomId)
 	logger.LogFirestoreOperation("READ", "chats/"+roomId+"/messages", "", true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(messages)
+	writeJSON(w, r, http.StatusOK, messages)
 }
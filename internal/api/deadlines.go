@@ -0,0 +1,28 @@
+package api
+
+import (
+	"time"
+
+	"pawtroli-be/internal/middleware"
+)
+
+// RequestDeadlines bounds how long each route may take. Routes that are not listed get
+// the default; streams and downloads have no deadline.
+var RequestDeadlines = middleware.Deadlines{
+	Default: 5 * time.Second,
+	Routes: map[string]time.Duration{
+		"POST /register":                  10 * time.Second,
+		"GET /pets":                       10 * time.Second,
+		"DELETE /me":                      10 * time.Second,
+		"GET /admin/users":                10 * time.Second,
+		"GET /admin/users/{uid}":          10 * time.Second,
+		"PUT /admin/users/{uid}/role":     10 * time.Second,
+		"POST /admin/users/{uid}/disable": 10 * time.Second,
+		"POST /admin/users/{uid}/enable":  10 * time.Second,
+		"GET /admin/audit":                10 * time.Second,
+		"GET /admin/audit/export":         2 * time.Minute,
+		"GET /admin/logs/search":          30 * time.Second,
+		"GET /admin/logs/{name}":          0,
+		"GET /admin/logs/{name}/tail":     0,
+	},
+}
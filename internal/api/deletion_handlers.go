@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+var deletionService *services.DeletionService
+
+// SetDeletionService sets the deletion service for the handlers
+func SetDeletionService(ds *services.DeletionService) {
+	deletionService = ds
+}
+
+// startDeletion creates a deletion task for the target and runs it in the background
+func startDeletion(ctx context.Context, kind, targetID string) (*models.DeletionTask, error) {
+	if deletionService == nil {
+		return nil, apperror.Internal("Deletion service not available", nil)
+	}
+	task, err := deletionService.CreateTask(ctx, kind, targetID)
+	if err != nil {
+		return nil, apperror.Internal("Failed to create deletion task", err)
+	}
+	if task.Status != services.DeletionDone {
+		if err := queueJob(ctx, JobDeletion, deletionPayload{TaskID: task.ID}); err != nil {
+			logger.LogErrorf("Failed to queue deletion task %s: %v", task.ID, err)
+			return nil, apperror.Internal("Failed to queue deletion task", err)
+		}
+	}
+	return task, nil
+}
+
+// validateMedia returns a validation error unless the media URL of field is hosted outside
+// the app's bucket or stored under one of the prefixes
+func validateMedia(field, mediaURL string, prefixes ...string) error {
+	if deletionService == nil || deletionService.MediaAllowed(mediaURL, prefixes...) {
+		return nil
+	}
+	return apperror.Validation("Validation failed", apperror.FieldError{
+		Field:   field,
+		Message: "must be uploaded to " + strings.Join(prefixes, " or "),
+	})
+}
+
+// requireStaff writes a forbidden error and returns false unless the caller is staff
+func requireStaff(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	staff, err := isStaff(ctx, r)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return false
+	}
+	if !staff {
+		uid, _ := r.Context().Value("uid").(string)
+		logger.LogWarningf("Non-staff user %s denied access to %s", uid, r.URL.Path)
+		apperror.Write(w, r, apperror.Forbidden("Staff only"))
+		return false
+	}
+	return true
+}
+
+// POST /admin/pets/{petId}/purge
+// Permanently deletes a pet with its updates and media in the background.
+func PurgePet(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petId := mux.Vars(r)["petId"]
+	logger.LogInfof("PurgePet called for petId: %s by uid: %s", petId, uid)
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+
+	if _, err := firestoreClient.Collection("pets").Doc(petId).Get(ctx); err != nil {
+		if apperror.IsNotFound(err) {
+			apperror.Write(w, r, errPetNotFound)
+			return
+		}
+		apperror.Write(w, r, apperror.Internal("Failed to fetch pet", err))
+		return
+	}
+
+	task, err := startDeletion(ctx, services.DeletionPet, petId)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	audit(r, "pet.purge_requested", "pet", petId, nil, map[string]interface{}{"taskId": task.ID})
+	logger.LogInfof("Purge of pet %s queued as task %s", petId, task.ID)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusAccepted, time.Since(start))
+	w.Header().Set("Location", versionedPath(r, "/admin/deletions/"+task.ID))
+	writeJSON(w, r, http.StatusAccepted, dto.NewDeletionTaskResponse(*task))
+}
+
+// GET /admin/deletions/{taskId}
+func GetDeletionTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	taskId := mux.Vars(r)["taskId"]
+	logger.LogInfof("GetDeletionTask called for taskId: %s by uid: %s", taskId, uid)
+
+	ctx := r.Context()
+
+	if !requireStaff(ctx, w, r) {
+		return
+	}
+	if deletionService == nil {
+		apperror.Write(w, r, apperror.Internal("Deletion service not available", nil))
+		return
+	}
+
+	task, err := deletionService.GetTask(ctx, taskId)
+	if err == services.ErrDeletionTaskNotFound {
+		apperror.Write(w, r, apperror.NotFound("Deletion task not found"))
+		return
+	}
+	if err != nil {
+		apperror.Write(w, r, apperror.Internal("Failed to get deletion task", err))
+		return
+	}
+
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewDeletionTaskResponse(*task))
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pawtroli-be/internal/services"
+)
+
+var emailService *services.EmailService
+
+// SetEmailService sets the email service for the handlers and job handlers. Daily digests
+// are queued as a background job, so SetJobQueue must be called first.
+func SetEmailService(es *services.EmailService) {
+	emailService = es
+
+	es.SetDigestScheduler(func(ctx context.Context, from, to time.Time) error {
+		if jobQueue == nil {
+			return fmt.Errorf("job queue not initialized")
+		}
+		_, err := jobQueue.Enqueue(ctx, JobEmailDailyDigests, dailyDigestsPayload{From: from, To: to})
+		return err
+	})
+}
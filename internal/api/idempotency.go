@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/validation"
+)
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key header accepted
+const maxIdempotencyKeyLength = 255
+
+var idempotencyService *services.IdempotencyService
+
+// SetIdempotencyService sets the idempotency service for the handlers
+func SetIdempotencyService(is *services.IdempotencyService) {
+	idempotencyService = is
+}
+
+// idempotencyRecorder passes the response through while keeping a copy of it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (ir *idempotencyRecorder) WriteHeader(code int) {
+	if ir.statusCode == 0 {
+		ir.statusCode = code
+	}
+	ir.ResponseWriter.WriteHeader(code)
+}
+
+func (ir *idempotencyRecorder) Write(p []byte) (int, error) {
+	if ir.statusCode == 0 {
+		ir.statusCode = http.StatusOK
+	}
+	ir.body.Write(p)
+	return ir.ResponseWriter.Write(p)
+}
+
+// validIdempotencyKey reports whether key is 1 to 255 printable ASCII characters
+func validIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyContext returns the context for saving the outcome of r, which must succeed
+// even if the request context ended while the handler ran
+func idempotencyContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+}
+
+// idempotent makes retries of a request carrying an Idempotency-Key header safe. The first
+// response per caller and key is stored and replayed to retries with the same method, path
+// and body; a retry with a different request gets a 422 and one arriving while the first is
+// still running gets a 409. Server errors are not stored so the request can be retried.
+// Requests without the header are passed through.
+func idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || idempotencyService == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			apperror.Write(w, r, apperror.Validation("Idempotency-Key must be 1 to 255 printable ASCII characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, validation.DefaultMaxBodyBytes))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				apperror.Write(w, r, apperror.TooLarge("Request body too large"))
+				return
+			}
+			apperror.Write(w, r, apperror.Validation("Failed to read request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the caller, so idempotent routes must verify the token first
+		scope, _ := r.Context().Value("uid").(string)
+		if scope == "" {
+			logger.LogErrorf("Idempotency-Key sent to %s, which does not verify the caller", r.URL.Path)
+			apperror.Write(w, r, apperror.Internal("Idempotency-Key is not supported here", nil))
+			return
+		}
+		// Retries may switch between /v1 and the unversioned alias of the same route
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+middleware.UnversionedPath(r.URL.Path)+"\n")
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		record, token, err := idempotencyService.Begin(r.Context(), scope, key, requestHash)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			apperror.Write(w, r, apperror.Unprocessable("Idempotency-Key was already used for a different request"))
+			return
+		case errors.Is(err, services.ErrIdempotencyInProgress):
+			w.Header().Set("Retry-After", "1")
+			apperror.Write(w, r, apperror.Conflict("A request with this Idempotency-Key is still in progress"))
+			return
+		case err != nil:
+			apperror.Write(w, r, apperror.Internal("Failed to check Idempotency-Key", err))
+			return
+		case record != nil:
+			logger.LogInfof("[%s] Replaying response to Idempotency-Key %s", logger.RequestTag(r.Context()), record.ID)
+			if record.ContentType != "" {
+				w.Header().Set("Content-Type", record.ContentType)
+			}
+			if record.Location != "" {
+				w.Header().Set("Location", record.Location)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.Body)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			ctx, cancel := idempotencyContext(r)
+			defer cancel()
+			if err := idempotencyService.Release(ctx, scope, key, token); errors.Is(err, services.ErrIdempotencyClaimLost) {
+				logger.LogWarning("Idempotency-Key was taken over before it was released")
+			} else if err != nil {
+				logger.LogErrorf("Failed to release Idempotency-Key: %v", err)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.statusCode == 0 || rec.statusCode >= 500 {
+			return
+		}
+		// If the response cannot be stored the key stays locked rather than released, so
+		// that retries are not processed a second time before the lock expires
+		completed = true
+		ctx, cancel := idempotencyContext(r)
+		defer cancel()
+		if err := idempotencyService.Complete(ctx, scope, key, token, rec.statusCode, rec.Header().Get("Content-Type"), rec.Header().Get("Location"), rec.body.Bytes()); errors.Is(err, services.ErrIdempotencyClaimLost) {
+			// The request outlasted its lock and a retry claimed the key; its response wins
+			logger.LogWarning("Idempotency-Key was taken over before the response was stored")
+		} else if err != nil {
+			logger.LogErrorf("Failed to store response to Idempotency-Key: %v", err)
+		}
+	})
+}
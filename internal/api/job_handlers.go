@@ -0,0 +1,366 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+var jobQueue *services.JobQueue
+
+// Job types run by the background job queue
+const (
+	JobNotifyPetOwner    = "notification.pet_owner"
+	JobNotifyChat        = "notification.chat"
+	JobEmailReservation  = "email.reservation"
+	JobEmailCheckIn      = "email.check_in"
+	JobEmailInvoice      = "email.invoice"
+	JobEmailDailyDigests = "email.daily_digests"
+	JobEmailDailyDigest  = "email.daily_digest"
+	JobDeletion          = "deletion.run"
+	JobSyncClaims        = "auth.sync_claims"
+)
+
+type notifyPetOwnerPayload struct {
+	PetID        string                `json:"petId"`
+	Notification services.Notification `json:"notification"`
+	Delivered    []string              `json:"delivered,omitempty"` // devices reached by earlier attempts
+}
+
+type notifyChatPayload struct {
+	RoomID       string                `json:"roomId"`
+	SenderID     string                `json:"senderId"`
+	Notification services.Notification `json:"notification"`
+	Delivered    []string              `json:"delivered,omitempty"` // devices reached by earlier attempts
+}
+
+type petPayload struct {
+	PetID string `json:"petId"`
+}
+
+type userPayload struct {
+	UID string `json:"uid"`
+}
+
+type invoicePayload struct {
+	UID     string                    `json:"uid"`
+	Invoice services.InvoiceEmailData `json:"invoice"`
+}
+
+type dailyDigestsPayload struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Queued []string  `json:"queued,omitempty"` // owners whose digest was queued by earlier attempts
+}
+
+type dailyDigestPayload struct {
+	UID    string    `json:"uid"`
+	PetIDs []string  `json:"petIds"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+type deletionPayload struct {
+	TaskID string `json:"taskId"`
+}
+
+// SetJobQueue sets the job queue for the handlers and registers the job handlers
+func SetJobQueue(q *services.JobQueue) {
+	jobQueue = q
+
+	q.RegisterHandler(JobNotifyPetOwner, func(ctx context.Context, job *models.Job) error {
+		var p notifyPetOwnerPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		delivered, err := notificationService.NotifyPetOwner(ctx, p.PetID, p.Notification, p.Delivered)
+		if len(delivered) > len(p.Delivered) {
+			p.Delivered = delivered
+			if err := services.EncodeJobPayload(job, p); err != nil {
+				logger.LogErrorf("Failed to record delivered devices of job %s: %v", job.ID, err)
+			}
+		}
+		return err
+	}, 5)
+
+	q.RegisterHandler(JobNotifyChat, func(ctx context.Context, job *models.Job) error {
+		var p notifyChatPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		delivered, err := notificationService.NotifyChatParticipants(ctx, p.RoomID, p.SenderID, p.Notification, p.Delivered)
+		if len(delivered) > len(p.Delivered) {
+			p.Delivered = delivered
+			if err := services.EncodeJobPayload(job, p); err != nil {
+				logger.LogErrorf("Failed to record delivered devices of job %s: %v", job.ID, err)
+			}
+		}
+		return err
+	}, 5)
+
+	q.RegisterHandler(JobEmailReservation, func(ctx context.Context, job *models.Job) error {
+		if emailService == nil {
+			logger.LogWarningf("Email service not available, skipping job %s", job.ID)
+			return nil
+		}
+		var p petPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		return emailService.SendReservationConfirmation(ctx, p.PetID)
+	}, 2)
+
+	q.RegisterHandler(JobEmailCheckIn, func(ctx context.Context, job *models.Job) error {
+		if emailService == nil {
+			logger.LogWarningf("Email service not available, skipping job %s", job.ID)
+			return nil
+		}
+		var p petPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		return emailService.SendCheckIn(ctx, p.PetID)
+	}, 2)
+
+	q.RegisterHandler(JobEmailInvoice, func(ctx context.Context, job *models.Job) error {
+		if emailService == nil {
+			logger.LogWarningf("Email service not available, skipping job %s", job.ID)
+			return nil
+		}
+		var p invoicePayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		return emailService.SendInvoice(ctx, p.UID, p.Invoice)
+	}, 2)
+
+	// The daily digests job queues one job per owner, so a failing owner is retried alone
+	q.RegisterHandler(JobEmailDailyDigests, func(ctx context.Context, job *models.Job) error {
+		if emailService == nil {
+			logger.LogWarningf("Email service not available, skipping job %s", job.ID)
+			return nil
+		}
+		var p dailyDigestsPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		owners, err := emailService.DigestOwners(ctx, p.From, p.To)
+		if err != nil {
+			return err
+		}
+
+		queued := len(p.Queued)
+		for uid, petIDs := range owners {
+			if slices.Contains(p.Queued, uid) {
+				continue
+			}
+			if _, err = q.Enqueue(ctx, JobEmailDailyDigest, dailyDigestPayload{UID: uid, PetIDs: petIDs, From: p.From, To: p.To}); err != nil {
+				err = fmt.Errorf("failed to queue digest of %s: %v", uid, err)
+				break
+			}
+			p.Queued = append(p.Queued, uid)
+		}
+		if len(p.Queued) > queued {
+			if err := services.EncodeJobPayload(job, p); err != nil {
+				logger.LogErrorf("Failed to record queued digests of job %s: %v", job.ID, err)
+			}
+		}
+		return err
+	}, 1)
+
+	q.RegisterHandler(JobEmailDailyDigest, func(ctx context.Context, job *models.Job) error {
+		if emailService == nil {
+			logger.LogWarningf("Email service not available, skipping job %s", job.ID)
+			return nil
+		}
+		var p dailyDigestPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		return emailService.SendDailyDigest(ctx, p.UID, p.PetIDs, p.From, p.To)
+	}, 2)
+
+	q.RegisterHandler(JobDeletion, func(ctx context.Context, job *models.Job) error {
+		if deletionService == nil {
+			return fmt.Errorf("deletion service not available")
+		}
+		var p deletionPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		return deletionService.Run(ctx, p.TaskID)
+	}, 2)
+
+	q.RegisterHandler(JobSyncClaims, func(ctx context.Context, job *models.Job) error {
+		var p userPayload
+		if err := services.DecodeJobPayload(job, &p); err != nil {
+			return err
+		}
+		return syncClaims(ctx, p.UID)
+	}, 5)
+}
+
+// enqueueJob queues a background job, logging instead of failing the request if that is not possible.
+// Jobs follow up changes that are already applied, so they are queued even if the client went away.
+func enqueueJob(ctx context.Context, name string, payload interface{}) {
+	if err := queueJob(ctx, name, payload); err != nil {
+		logger.LogErrorf("Failed to enqueue %s job: %v", name, err)
+	}
+}
+
+// queueJob queues a background job like enqueueJob but returns the error, for requests that
+// must fail when their job cannot be queued
+func queueJob(ctx context.Context, name string, payload interface{}) error {
+	if jobQueue == nil {
+		return fmt.Errorf("job queue not initialized")
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	job, err := jobQueue.Enqueue(ctx, name, payload)
+	if err != nil {
+		return err
+	}
+	logger.LogInfof("Enqueued %s job %s", name, job.ID)
+	return nil
+}
+
+// GET /admin/jobs?status=dead&limit=50
+func GetJobs(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	status := r.URL.Query().Get("status")
+	logger.LogInfof("GetJobs called with status=%q by uid: %s", status, uid)
+
+	if !requireStaff(r.Context(), w, r) {
+		return
+	}
+
+	if jobQueue == nil {
+		logger.LogError("Job queue not initialized")
+		apperror.Write(w, r, apperror.Internal("Job queue not available", nil))
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 500 {
+			apperror.Write(w, r, apperror.Validation("Invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	ctx := r.Context()
+
+	jobs, err := jobQueue.List(ctx, status, limit)
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to list jobs: %v", err)
+		logger.LogFirestoreOperation("READ", "jobs", "", false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to list jobs", err))
+		return
+	}
+
+	logger.LogInfof("Retrieved %d jobs", len(jobs))
+	logger.LogFirestoreOperation("READ", "jobs", "", true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+
+	resp := make([]dto.JobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		resp = append(resp, dto.NewJobResponse(*job))
+	}
+	writeJSON(w, r, http.StatusOK, dto.JobListResponse{Jobs: resp, Count: len(jobs)})
+}
+
+// GET /admin/jobs/{jobId}
+func GetJob(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	jobId := mux.Vars(r)["jobId"]
+	logger.LogInfof("GetJob called for jobId: %s by uid: %s", jobId, uid)
+
+	if !requireStaff(r.Context(), w, r) {
+		return
+	}
+
+	if jobQueue == nil {
+		logger.LogError("Job queue not initialized")
+		apperror.Write(w, r, apperror.Internal("Job queue not available", nil))
+		return
+	}
+
+	ctx := r.Context()
+
+	job, err := jobQueue.Get(ctx, jobId)
+	if err == services.ErrJobNotFound {
+		apperror.Write(w, r, apperror.NotFound("Job not found"))
+		return
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to get job: %v", err)
+		apperror.Write(w, r, apperror.Internal("Failed to get job", err))
+		return
+	}
+
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewJobResponse(*job))
+}
+
+// POST /admin/jobs/{jobId}/retry
+func RetryJob(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	jobId := mux.Vars(r)["jobId"]
+	logger.LogInfof("RetryJob called for jobId: %s by uid: %s", jobId, uid)
+
+	if !requireStaff(r.Context(), w, r) {
+		return
+	}
+
+	if jobQueue == nil {
+		logger.LogError("Job queue not initialized")
+		apperror.Write(w, r, apperror.Internal("Job queue not available", nil))
+		return
+	}
+
+	ctx := r.Context()
+
+	job, err := jobQueue.Get(ctx, jobId)
+	if err == services.ErrJobNotFound {
+		apperror.Write(w, r, apperror.NotFound("Job not found"))
+		return
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to get job: %v", err)
+		apperror.Write(w, r, apperror.Internal("Failed to get job", err))
+		return
+	}
+	if job.Status != services.JobDead {
+		apperror.Write(w, r, apperror.Conflict("Only dead jobs can be retried"))
+		return
+	}
+
+	job, err = jobQueue.Retry(ctx, jobId)
+	if err != nil {
+		logger.LogErrorf("Failed to retry job: %v", err)
+		apperror.Write(w, r, apperror.Internal("Failed to retry job", err))
+		return
+	}
+
+	audit(r, "job.retried", "job", jobId, map[string]interface{}{"status": services.JobDead}, map[string]interface{}{"status": job.Status})
+	logger.LogInfof("Job %s requeued", jobId)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewJobResponse(*job))
+}
@@ -1,20 +1,47 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"github.com/gorilla/mux"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/metrics"
+	"pawtroli-be/internal/middleware"
 	"pawtroli-be/internal/services"
+
+	"github.com/gorilla/mux"
 )
 
 var logRotationService *services.LogRotationService
 
 func AdminRoutes(r *mux.Router) {
 	admin := r.PathPrefix("/admin").Subrouter()
-	admin.HandleFunc("/logs", GetLogFiles).Methods("GET")
+	admin.Handle("/logs", middleware.VerifyToken(http.HandlerFunc(GetLogFiles))).Methods("GET")
+	admin.Handle("/logs/search", middleware.VerifyToken(http.HandlerFunc(SearchLogs))).Methods("GET")
+	admin.Handle("/logs/{name}", middleware.VerifyToken(http.HandlerFunc(DownloadLogFile))).Methods("GET")
+	admin.Handle("/logs/{name}/lines", middleware.VerifyToken(http.HandlerFunc(GetLogLines))).Methods("GET")
+	admin.Handle("/logs/{name}/tail", middleware.VerifyToken(http.HandlerFunc(TailLogFile))).Methods("GET")
+	admin.Handle("/jobs", middleware.VerifyToken(http.HandlerFunc(GetJobs))).Methods("GET")
+	admin.Handle("/jobs/{jobId}", middleware.VerifyToken(http.HandlerFunc(GetJob))).Methods("GET")
+	admin.Handle("/jobs/{jobId}/retry", middleware.VerifyToken(http.HandlerFunc(RetryJob))).Methods("POST")
+	admin.Handle("/pets/{petId}/purge", middleware.VerifyToken(http.HandlerFunc(PurgePet))).Methods("POST")
+	admin.Handle("/deletions/{taskId}", middleware.VerifyToken(http.HandlerFunc(GetDeletionTask))).Methods("GET")
+	admin.Handle("/audit", middleware.VerifyToken(http.HandlerFunc(GetAuditLog))).Methods("GET")
+	admin.Handle("/audit/export", middleware.VerifyToken(http.HandlerFunc(ExportAuditLog))).Methods("GET")
+	admin.Handle("/users", middleware.VerifyToken(http.HandlerFunc(ListUsers))).Methods("GET")
+	admin.Handle("/users/{uid}", middleware.VerifyToken(http.HandlerFunc(GetUserDetails))).Methods("GET")
+	admin.Handle("/users/{uid}/role", middleware.VerifyToken(http.HandlerFunc(ChangeUserRole))).Methods("PUT")
+	admin.Handle("/users/{uid}/disable", middleware.VerifyToken(http.HandlerFunc(DisableUser))).Methods("POST")
+	admin.Handle("/users/{uid}/enable", middleware.VerifyToken(http.HandlerFunc(EnableUser))).Methods("POST")
 }
 
 // SetLogRotationService sets the log rotation service for the handlers
@@ -27,25 +54,280 @@ func GetLogFiles(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	logger.LogInfo("GetLogFiles called")
 
-	if logRotationService == nil {
-		logger.LogError("Log rotation service not initialized")
-		http.Error(w, "Log service not available", http.StatusInternalServerError)
+	ctx := r.Context()
+	if !requireStaff(ctx, w, r) || !logServiceAvailable(w, r) {
 		return
 	}
 
 	files, err := logRotationService.GetLogFilesList()
 	if err != nil {
 		logger.LogErrorf("Failed to get log files list: %v", err)
-		http.Error(w, "Failed to get log files", http.StatusInternalServerError)
+		apperror.Write(w, r, apperror.Internal("Failed to get log files", err))
 		return
 	}
 
 	logger.LogInfof("Retrieved %d log files", len(files))
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"files": files,
-		"count": len(files),
+	writeJSON(w, r, http.StatusOK, dto.LogFileListResponse{Files: files, Count: len(files)})
+}
+
+// logServiceAvailable writes an error and returns false if the log rotation service is missing
+func logServiceAvailable(w http.ResponseWriter, r *http.Request) bool {
+	if logRotationService == nil {
+		logger.LogError("Log rotation service not initialized")
+		apperror.Write(w, r, apperror.Internal("Log service not available", nil))
+		return false
+	}
+	return true
+}
+
+// logFileError maps errors resolving a log file to API errors
+func logFileError(err error) error {
+	if err == services.ErrLogFileNotFound {
+		return apperror.NotFound("Log file not found")
+	}
+	if err == services.ErrLogFileCompressed {
+		return apperror.Validation("Compressed log files cannot be tailed")
+	}
+	return apperror.Internal("Failed to read log file", err)
+}
+
+// GET /admin/logs/{name} - Download a log file (supports Range requests)
+func DownloadLogFile(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	name := mux.Vars(r)["name"]
+	logger.LogInfof("DownloadLogFile called for %q", name)
+
+	ctx := r.Context()
+	if !requireStaff(ctx, w, r) || !logServiceAvailable(w, r) {
+		return
+	}
+
+	path, err := logRotationService.ResolveLogFile(name)
+	if err != nil {
+		logger.LogWarningf("Rejected log file download %q: %v", name, err)
+		apperror.Write(w, r, logFileError(err))
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		apperror.Write(w, r, logFileError(err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		apperror.Write(w, r, logFileError(err))
+		return
+	}
+
+	if strings.HasSuffix(info.Name(), ".gz") {
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+info.Name()+`"`)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+}
+
+// GET /admin/logs/{name}/lines?from=1&limit=500 - Read a range of lines
+func GetLogLines(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	name := mux.Vars(r)["name"]
+	logger.LogInfof("GetLogLines called for %q", name)
+
+	ctx := r.Context()
+	if !requireStaff(ctx, w, r) || !logServiceAvailable(w, r) {
+		return
+	}
+
+	from, err := queryInt(r, "from", 1, 1, 1<<31-1)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", 500, 1, 5000)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	lines, more, err := logRotationService.ReadLines(name, from, limit)
+	if err != nil {
+		apperror.Write(w, r, logFileError(err))
+		return
+	}
+
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.LogLinesResponse{File: name, From: from, Lines: lines, Count: len(lines), HasMore: more})
+}
+
+// GET /admin/logs/{name}/tail?lines=50 - Stream new lines as Server-Sent Events, ending with
+// a "rotated" event naming the new file once the logger moves on from this one
+func TailLogFile(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	logger.LogInfof("TailLogFile called for %q", name)
+
+	ctx := r.Context()
+	if !requireStaff(ctx, w, r) || !logServiceAvailable(w, r) {
+		return
+	}
+
+	n, err := queryInt(r, "lines", 50, 0, 1000)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+	lines, offset, err := logRotationService.LastLines(name, n)
+	if err != nil {
+		apperror.Write(w, r, logFileError(err))
+		return
+	}
+
+	metrics.ActiveStreams.Add(1, "log_tail")
+	defer metrics.ActiveStreams.Add(-1, "log_tail")
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(line services.LogLine) error {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: line\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	for _, line := range lines {
+		if err := send(line); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		logger.LogWarningf("Log tail cannot be streamed: %v", err)
+		return
+	}
+
+	// Keep proxies from closing an idle stream; mu serializes writes with the tail
+	var mu sync.Mutex
+	streamCtx, stop := context.WithCancel(r.Context())
+	defer stop()
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-streamCtx.Done():
+				return
+			case <-ticker.C:
+				mu.Lock()
+				_, err := fmt.Fprint(w, ": keep-alive\n\n")
+				if err == nil {
+					err = rc.Flush()
+				}
+				mu.Unlock()
+				if err != nil {
+					stop()
+					return
+				}
+			}
+		}
+	}()
+
+	err = logRotationService.TailLogFile(streamCtx, name, offset, func(line services.LogLine) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return send(line)
 	})
+	if err == services.ErrLogRotated {
+		// Tell the client which file to follow next instead of going silent
+		data, _ := json.Marshal(map[string]string{"file": logger.CurrentLogFile()})
+		mu.Lock()
+		fmt.Fprintf(w, "event: rotated\ndata: %s\n\n", data)
+		rc.Flush()
+		mu.Unlock()
+		return
+	}
+	if err != nil {
+		logger.LogWarningf("Log tail of %q ended: %v", name, err)
+	}
+}
+
+// GET /admin/logs/search?level=ERROR&from=&to=&q=&requestId=&limit=200 - Search retained logs
+func SearchLogs(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q := r.URL.Query()
+	logger.LogInfof("SearchLogs called with filters %v", q)
+
+	ctx := r.Context()
+	if !requireStaff(ctx, w, r) || !logServiceAvailable(w, r) {
+		return
+	}
+
+	query := services.LogSearchQuery{
+		Level:     strings.ToUpper(q.Get("level")),
+		Contains:  q.Get("q"),
+		RequestID: q.Get("requestId"),
+	}
+	var fields []apperror.FieldError
+	switch query.Level {
+	case "", "DEBUG", "INFO", "WARNING", "ERROR":
+	default:
+		fields = append(fields, apperror.FieldError{Field: "level", Message: "must be one of: DEBUG, INFO, WARNING, ERROR"})
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				fields = append(fields, apperror.FieldError{Field: p.name, Message: "must be an RFC 3339 timestamp"})
+			}
+			*p.dst = t
+		}
+	}
+	if len(fields) > 0 {
+		apperror.Write(w, r, apperror.Validation("Invalid search", fields...))
+		return
+	}
+	limit, err := queryInt(r, "limit", 200, 1, 2000)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+	query.Limit = limit
+
+	results, err := logRotationService.SearchLogs(ctx, query)
+	if err != nil {
+		logger.LogErrorf("Log search failed: %v", err)
+		apperror.Write(w, r, apperror.Internal("Log search failed", err))
+		return
+	}
+
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.LogSearchResponse{Results: results, Count: len(results), Truncated: len(results) == limit})
+}
+
+// queryInt parses an optional integer query parameter within [min, max]
+func queryInt(r *http.Request, name string, def, min, max int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, apperror.Validation("Validation failed", apperror.FieldError{
+			Field:   name,
+			Message: fmt.Sprintf("must be a number between %d and %d", min, max),
+		})
+	}
+	return n, nil
 }
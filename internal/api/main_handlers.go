@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+
 	"cloud.google.com/go/firestore"
 
 	"pawtroli-be/internal/firebase"
@@ -19,4 +20,9 @@ func InitHandlers() {
 	}
 	firestoreClient = client
 	logger.LogInfo("✅ Firestore client initialized")
-}
\ No newline at end of file
+}
+
+// FirestoreClient returns the Firestore client shared by the handlers
+func FirestoreClient() *firestore.Client {
+	return firestoreClient
+}
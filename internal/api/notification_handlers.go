@@ -0,0 +1,174 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/validation"
+
+	"github.com/gorilla/mux"
+)
+
+var notificationService *services.NotificationService
+
+func NotificationRoutes(r *mux.Router) {
+	devices := r.PathPrefix("/devices").Subrouter()
+	devices.Handle("", middleware.VerifyToken(http.HandlerFunc(RegisterDevice))).Methods("POST")
+	devices.Handle("/{token}", middleware.VerifyToken(http.HandlerFunc(UnregisterDevice))).Methods("DELETE")
+
+	prefs := r.PathPrefix("/notifications/preferences").Subrouter()
+	prefs.Handle("", middleware.VerifyToken(http.HandlerFunc(GetNotificationPreferences))).Methods("GET")
+	prefs.Handle("", middleware.VerifyToken(http.HandlerFunc(UpdateNotificationPreferences))).Methods("PUT")
+}
+
+// SetNotificationService sets the notification service for the handlers
+func SetNotificationService(ns *services.NotificationService) {
+	notificationService = ns
+}
+
+// POST /devices
+func RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("RegisterDevice called for uid: %s", uid)
+
+	if notificationService == nil {
+		logger.LogError("Notification service not initialized")
+		apperror.Write(w, r, apperror.Internal("Notification service not available", nil))
+		return
+	}
+
+	req := new(dto.RegisterDeviceRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode device token: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	device := req.ToModel(time.Now())
+	err := notificationService.RegisterDevice(ctx, uid, device)
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to register device: %v", err)
+		logger.LogFirestoreOperation("CREATE", "users/"+uid+"/devices", "", false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to register device", err))
+		return
+	}
+
+	audit(r, "device.registered", "device", redactToken(device.Token), nil, map[string]interface{}{"platform": device.Platform})
+	logger.LogInfof("Device registered for uid: %s (platform=%s)", uid, device.Platform)
+	logger.LogFirestoreOperation("CREATE", "users/"+uid+"/devices", "", true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, time.Since(start))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /devices/{token}
+func UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	token := mux.Vars(r)["token"]
+	logger.LogInfof("UnregisterDevice called for uid: %s", uid)
+
+	if notificationService == nil {
+		logger.LogError("Notification service not initialized")
+		apperror.Write(w, r, apperror.Internal("Notification service not available", nil))
+		return
+	}
+
+	ctx := r.Context()
+
+	err := notificationService.UnregisterDevice(ctx, uid, token)
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to unregister device: %v", err)
+		logger.LogFirestoreOperation("DELETE", "users/"+uid+"/devices", "", false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to unregister device", err))
+		return
+	}
+
+	audit(r, "device.unregistered", "device", redactToken(token), nil, nil)
+	logger.LogInfof("Device unregistered for uid: %s", uid)
+	logger.LogFirestoreOperation("DELETE", "users/"+uid+"/devices", "", true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, time.Since(start))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /notifications/preferences
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("GetNotificationPreferences called for uid: %s", uid)
+
+	if notificationService == nil {
+		logger.LogError("Notification service not initialized")
+		apperror.Write(w, r, apperror.Internal("Notification service not available", nil))
+		return
+	}
+
+	ctx := r.Context()
+
+	prefs, err := notificationService.GetPreferences(ctx, uid)
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to get notification preferences: %v", err)
+		logger.LogFirestoreOperation("READ", "notification_preferences", uid, false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to get notification preferences", err))
+		return
+	}
+
+	logger.LogFirestoreOperation("READ", "notification_preferences", uid, true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewNotificationPreferencesResponse(prefs))
+}
+
+// PUT /notifications/preferences
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("UpdateNotificationPreferences called for uid: %s", uid)
+
+	if notificationService == nil {
+		logger.LogError("Notification service not initialized")
+		apperror.Write(w, r, apperror.Internal("Notification service not available", nil))
+		return
+	}
+
+	req := new(dto.NotificationPreferencesRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode notification preferences: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	before, err := notificationService.GetPreferences(ctx, uid)
+	if err != nil {
+		logger.LogErrorf("Failed to get notification preferences: %v", err)
+		apperror.Write(w, r, apperror.Internal("Failed to get notification preferences", err))
+		return
+	}
+	prefs := req.ToModel()
+	err = notificationService.SetPreferences(ctx, uid, prefs)
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogErrorf("Failed to save notification preferences: %v", err)
+		logger.LogFirestoreOperation("UPDATE", "notification_preferences", uid, false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to save notification preferences", err))
+		return
+	}
+
+	audit(r, "notification_preferences.updated", "notification_preferences", uid,
+		dto.NewNotificationPreferencesResponse(before), dto.NewNotificationPreferencesResponse(prefs))
+	logger.LogInfof("Notification preferences updated for uid: %s", uid)
+	logger.LogFirestoreOperation("UPDATE", "notification_preferences", uid, true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewNotificationPreferencesResponse(prefs))
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+// operation documents a route in the OpenAPI document
+type operation struct {
+	tag         string
+	summary     string
+	description string
+	query       []openapi.Parameter
+	request     interface{} // type of the JSON body, nil without a body
+	status      int         // success status, 200 when zero
+	response    interface{} // type of the JSON response, nil without a body
+	contentType string      // of a response that is not JSON, or an alternative to JSON
+	idempotent  bool        // accepts an Idempotency-Key header
+	deprecated  bool
+}
+
+// query parameter helpers for the operations table
+func queryString(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+func queryTime(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string", Format: "date-time"}}
+}
+
+func queryInteger(name, description string, min, max int) openapi.Parameter {
+	lo, hi := float64(min), float64(max)
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "integer", Minimum: &lo, Maximum: &hi}}
+}
+
+func queryEnum(name, description string, values ...string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string", Enum: values}}
+}
+
+// auditFilterParams are the filters shared by the audit log and its export
+var auditFilterParams = []openapi.Parameter{
+	queryString("actor", "UID of the user who made the change"),
+	queryString("action", `Action, e.g. "user.role_changed"`),
+	queryString("resourceType", "Type of the changed resource"),
+	queryString("resourceId", "ID of the changed resource"),
+	queryTime("from", "Earliest timestamp, inclusive"),
+	queryTime("to", "Latest timestamp, exclusive"),
+}
+
+// operations documents every route, keyed by method and unversioned route template like
+// RequestDeadlines.
+// DocsRoutes fails when a registered route is missing here.
+var operations = map[string]operation{
+	"POST /register": {tag: "Users", summary: "Register the authenticated user",
+		description: "The user ID is taken from the token and the email from its claims.",
+		request:     dto.RegisterUserRequest{}, response: dto.StatusResponse{}},
+	"POST /login": {tag: "Users", summary: "Log in and fetch the profile", response: dto.LoginResponse{}},
+	"GET /me":     {tag: "Users", summary: "Get the caller's profile", response: dto.UserResponse{}},
+	"PATCH /me":   {tag: "Users", summary: "Update the caller's profile", description: "Merge patch: absent fields are kept, null clears optional fields. A photoUrl in the app's storage bucket must be under users/{uid}/.", request: dto.UpdateProfileRequest{}, response: dto.UserResponse{}},
+	"DELETE /me":  {tag: "Users", summary: "Delete the caller's account", description: "The account is disabled right away; data is removed or anonymized in the background.", status: http.StatusAccepted, response: dto.DeletionTaskResponse{}},
+
+	"GET /pets": {tag: "Pets", summary: "List pets", description: "Owners see their own pets, staff see every pet.",
+		query: []openapi.Parameter{
+			queryEnum("active", "Only active or inactive pets", "true", "false"),
+			queryString("status", "Only pets with this status"),
+			queryString("type", "Only pets of this type"),
+			queryInteger("limit", "Maximum number of pets", 1, 500),
+		},
+		response: dto.PetListResponse{}},
+	"POST /pets":                   {tag: "Pets", summary: "Create a pet owned by the caller", request: dto.CreatePetRequest{}, status: http.StatusCreated, response: dto.PetResponse{}},
+	"GET /pets/{petId}":            {tag: "Pets", summary: "Get a pet", description: "Only the owner and staff can see a pet.", response: dto.PetResponse{}},
+	"PATCH /pets/{petId}":          {tag: "Pets", summary: "Update a pet", description: "Merge patch: absent fields are kept.", request: dto.UpdatePetRequest{}, response: dto.PetResponse{}},
+	"DELETE /pets/{petId}":         {tag: "Pets", summary: "Soft delete a pet", description: "The pet and its updates are hidden until restored.", status: http.StatusNoContent},
+	"DELETE /pets/{petId}/delete":  {tag: "Pets", summary: "Soft delete a pet", description: "Use DELETE /pets/{petId}.", status: http.StatusNoContent, deprecated: true},
+	"POST /pets/{petId}/restore":   {tag: "Pets", summary: "Restore a soft deleted pet", response: dto.PetResponse{}},
+	"PATCH /pets/{petId}/reserve":  {tag: "Pets", summary: "Reserve a stay for a pet", description: "The owner is emailed a confirmation; the pet must not be checked in.", request: dto.ReservePetRequest{}, status: http.StatusNoContent},
+	"PATCH /pets/{petId}/activate": {tag: "Pets", summary: "Check a pet in", description: "Staff only.", request: dto.ActivatePetRequest{}, status: http.StatusNoContent},
+	"PATCH /pets/{petId}/checkout": {tag: "Pets", summary: "Check a pet out", description: "Staff only. Ends the stay now; the pet must be checked in.", status: http.StatusNoContent},
+	"POST /pets/{petId}/invoice":   {tag: "Pets", summary: "Email the owner an invoice", description: "Staff only. The total is the sum of the item amounts.", request: dto.InvoiceRequest{}, status: http.StatusAccepted},
+	"POST /pets/{petId}/updates":   {tag: "Pets", summary: "Post an update about a pet", description: "Staff only. Also sets the pet's status to the caption.", request: dto.CreatePetUpdateRequest{}, status: http.StatusCreated, response: dto.PetUpdateResponse{}, idempotent: true},
+	"GET /pets/{petId}/updates":    {tag: "Pets", summary: "List the updates of a pet", description: "Only the owner and staff can see them.", response: []dto.PetUpdateResponse{}},
+
+	"POST /chats":                   {tag: "Chats", summary: "Create or fetch the chat room of a group of users", description: "The caller must be one of the users.", request: dto.CreateChatRoomRequest{}, response: dto.ChatRoomResponse{}},
+	"POST /chats/{roomId}/messages": {tag: "Chats", summary: "Send a message as the caller", description: "Only participants of the room can post to it.", request: dto.SendMessageRequest{}, response: dto.MessageResponse{}, idempotent: true},
+	"GET /chats/{roomId}/messages":  {tag: "Chats", summary: "List the messages of a room, oldest first", description: "Only participants of the room can read it.", response: []dto.MessageResponse{}},
+
+	"POST /devices":                  {tag: "Notifications", summary: "Register a device for push notifications", description: "A device registered to another account before is moved to the caller.", request: dto.RegisterDeviceRequest{}, status: http.StatusNoContent},
+	"DELETE /devices/{token}":        {tag: "Notifications", summary: "Unregister a device", status: http.StatusNoContent},
+	"GET /notifications/preferences": {tag: "Notifications", summary: "Get the caller's notification preferences", response: dto.NotificationPreferencesResponse{}},
+	"PUT /notifications/preferences": {tag: "Notifications", summary: "Replace the caller's notification preferences", request: dto.NotificationPreferencesRequest{}, response: dto.NotificationPreferencesResponse{}},
+
+	"GET /admin/users": {tag: "Admin", summary: "List users",
+		query: []openapi.Parameter{
+			queryString("q", "Email prefix"),
+			queryEnum("role", "Only users with this role", "user", "admin"),
+			queryInteger("limit", "Page size", 1, 200),
+			queryString("cursor", "nextCursor of the previous page"),
+		},
+		response: dto.UserListResponse{}},
+	"GET /admin/users/{uid}":          {tag: "Admin", summary: "Get a user with their pets and stays", response: dto.AdminUserResponse{}},
+	"PUT /admin/users/{uid}/role":     {tag: "Admin", summary: "Change the role and branch of a user", request: dto.ChangeRoleRequest{}, status: http.StatusNoContent},
+	"POST /admin/users/{uid}/disable": {tag: "Admin", summary: "Disable a user", status: http.StatusNoContent},
+	"POST /admin/users/{uid}/enable":  {tag: "Admin", summary: "Enable a user", status: http.StatusNoContent},
+	"GET /admin/audit": {tag: "Admin", summary: "List audit log entries, newest first",
+		query: append(append([]openapi.Parameter(nil), auditFilterParams...),
+			queryInteger("limit", "Page size", 1, 500),
+			queryString("cursor", "nextCursor of the previous page"),
+		),
+		response: dto.AuditListResponse{}},
+	"GET /admin/audit/export": {tag: "Admin", summary: "Download audit log entries",
+		description: "The X-Export-Status trailer is complete or failed. A failed JSON export is not a closed array; a failed CSV export ends with a #export-failed row.",
+		query: append(append([]openapi.Parameter(nil), auditFilterParams...),
+			queryEnum("format", "File format, json by default", "json", "csv"),
+		),
+		response: []dto.AuditEntryResponse{}, contentType: "text/csv"},
+	"POST /admin/pets/{petId}/purge": {tag: "Admin", summary: "Permanently delete a pet with its updates and media", status: http.StatusAccepted, response: dto.DeletionTaskResponse{}},
+	"GET /admin/deletions/{taskId}":  {tag: "Admin", summary: "Get the progress of a deletion", response: dto.DeletionTaskResponse{}},
+	"GET /admin/jobs": {tag: "Admin", summary: "List background jobs",
+		query: []openapi.Parameter{
+			queryEnum("status", "Only jobs with this status", "pending", "running", "succeeded", "dead"),
+			queryInteger("limit", "Maximum number of jobs", 1, 500),
+		},
+		response: dto.JobListResponse{}},
+	"GET /admin/jobs/{jobId}":        {tag: "Admin", summary: "Get a background job", response: dto.JobResponse{}},
+	"POST /admin/jobs/{jobId}/retry": {tag: "Admin", summary: "Retry a dead job", response: dto.JobResponse{}},
+	"GET /admin/logs":                {tag: "Logs", summary: "List the retained log files", response: dto.LogFileListResponse{}},
+	"GET /admin/logs/search": {tag: "Logs", summary: "Search the retained logs",
+		query: []openapi.Parameter{
+			queryEnum("level", "Only lines of this level", "DEBUG", "INFO", "WARNING", "ERROR"),
+			queryTime("from", "Earliest time, inclusive"),
+			queryTime("to", "Latest time, exclusive"),
+			queryString("q", "Case insensitive text"),
+			queryString("requestId", "Only lines of this request"),
+			queryInteger("limit", "Maximum number of lines", 1, 2000),
+		},
+		response: dto.LogSearchResponse{}},
+	"GET /admin/logs/{name}": {tag: "Logs", summary: "Download a log file", description: "Supports Range requests; compressed files are served as application/gzip.", contentType: "text/plain"},
+	"GET /admin/logs/{name}/lines": {tag: "Logs", summary: "Read a range of lines of a log file",
+		query: []openapi.Parameter{
+			queryInteger("from", "First line, starting at 1", 1, 1<<31-1),
+			queryInteger("limit", "Maximum number of lines", 1, 5000),
+		},
+		response: dto.LogLinesResponse{}},
+	"GET /admin/logs/{name}/tail": {tag: "Logs", summary: "Stream new lines of the current log file",
+		description: "Sends a `line` event per log line. When the log rotates the stream ends with a `rotated` event whose data names the new file.",
+		query:       []openapi.Parameter{queryInteger("lines", "Number of existing lines sent first", 0, 1000)},
+		contentType: "text/event-stream"},
+
+	"GET /metrics":      {tag: "Operations", summary: "Prometheus metrics", description: "Requires the metrics bearer token when one is configured.", contentType: "text/plain"},
+	"GET /openapi.json": {tag: "Operations", summary: "This OpenAPI document", contentType: "application/json"},
+	"GET /docs":         {tag: "Operations", summary: "API documentation", contentType: "text/html"},
+}
+
+// build returns the OpenAPI operation; auth is set for routes wrapped in VerifyToken
+func (o operation) build(doc *openapi.Document, id string, auth bool) *openapi.Operation {
+	errorContent := map[string]*openapi.MediaType{"application/json": {Schema: doc.SchemaOf(apperror.ErrorResponse{})}}
+	op := &openapi.Operation{
+		OperationID: id,
+		Summary:     o.summary,
+		Description: o.description,
+		Tags:        []string{o.tag},
+		Deprecated:  o.deprecated,
+		Parameters:  o.query,
+		Responses: map[string]*openapi.Response{
+			"429":     {Description: "Rate limit exceeded; see Retry-After", Content: errorContent},
+			"default": {Description: "Error", Content: errorContent},
+		},
+	}
+	if o.idempotent {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Unique key of the request; retries with the same key and body get the stored response",
+			Schema:      &openapi.Schema{Type: "string", MaxLength: intPtr(maxIdempotencyKeyLength)},
+		})
+	}
+	if o.request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]*openapi.MediaType{"application/json": {Schema: doc.SchemaOf(o.request)}},
+		}
+		op.Responses["400"] = &openapi.Response{Description: "Invalid request", Content: errorContent}
+	}
+	if auth {
+		op.Security = []map[string][]string{{"firebase": {}}}
+		op.Responses["401"] = &openapi.Response{Description: "Missing or invalid token", Content: errorContent}
+	}
+
+	status := o.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &openapi.Response{Description: http.StatusText(status)}
+	if o.response != nil || o.contentType != "" {
+		success.Content = map[string]*openapi.MediaType{}
+	}
+	if o.response != nil {
+		success.Content["application/json"] = &openapi.MediaType{Schema: doc.SchemaOf(o.response)}
+	}
+	if o.contentType != "" {
+		success.Content[o.contentType] = &openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	return op
+}
+
+func intPtr(n int) *int { return &n }
+
+// buildOpenAPI documents the routes of r and returns the routes missing from operations
+func buildOpenAPI(r *mux.Router) (*openapi.Document, []string) {
+	doc := openapi.New(openapi.Info{
+		Title:       "Pawtroli API",
+		Description: "Backend of the Pawtroli pet boarding apps. Errors are returned as a JSON envelope with a code, message and request ID.",
+		Version:     "1.0.0",
+	})
+	doc.Components.SecuritySchemes["firebase"] = &openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Firebase Authentication ID token",
+	}
+
+	var missing []string
+	tags := map[string]bool{}
+	r.Walk(func(route *mux.Route, _ *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		methods, merr := route.GetMethods()
+		if route.GetHandler() == nil || err != nil || merr != nil {
+			return nil
+		}
+		legacy := len(ancestors) > 0 && ancestors[0] == legacyRoute
+		for _, method := range methods {
+			key := method + " " + middleware.UnversionedTemplate(template)
+			o, ok := operations[key]
+			if !ok {
+				missing = append(missing, key)
+				continue
+			}
+			if legacy {
+				o.deprecated = true
+				o.description = strings.TrimSpace(fmt.Sprintf("%s Deprecated alias of /%s%s, removed on %s.",
+					o.description, legacyVersion, template, LegacyDeprecation.Sunset.Format("2006-01-02")))
+			}
+			doc.AddOperation(method, template, o.build(doc, operationID(method, template), middleware.RequiresToken(route.GetHandler())))
+			if !tags[o.tag] {
+				tags[o.tag] = true
+				doc.Tags = append(doc.Tags, openapi.Tag{Name: o.tag})
+			}
+		}
+		return nil
+	})
+	return doc, missing
+}
+
+// operationID derives an ID like "get_pets_petId_updates" from a route
+func operationID(method, template string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.Split(template, "/") {
+		part = strings.Trim(part, "{}")
+		if part != "" {
+			id += "_" + strings.NewReplacer(".", "_", "-", "_").Replace(part)
+		}
+	}
+	return id
+}
+
+// DocsRoutes serves the OpenAPI document of the routes registered on r and its
+// documentation UI. It must be called after every other route is registered, and returns
+// an error listing the routes not documented in operations, which the document leaves out.
+func DocsRoutes(r *mux.Router) error {
+	r.Handle("/docs", openapi.DocsHandler("Pawtroli API", "/openapi.json")).Methods("GET")
+	specRoute := r.Handle("/openapi.json", http.NotFoundHandler()).Methods("GET")
+
+	doc, missing := buildOpenAPI(r)
+	specRoute.Handler(openapi.Handler(doc))
+	if len(missing) > 0 {
+		return fmt.Errorf("routes missing from the OpenAPI document: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
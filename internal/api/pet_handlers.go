@@ -2,180 +2,681 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
 	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/validation"
 
 	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
 
 	"github.com/gorilla/mux"
 )
 
 func PetRoutes(r *mux.Router) {
 	pets := r.PathPrefix("/pets").Subrouter()
-	pets.HandleFunc("/{id}", GetPet).Methods("GET")
-	pets.HandleFunc("", CreatePet).Methods("POST")
-	pets.HandleFunc("/{petId}/activate", ActivatePet).Methods("PATCH")
-	pets.HandleFunc("/{petId}/updates", CreatePetUpdate).Methods("POST")
-	pets.HandleFunc("/{petId}/updates", GetPetUpdates).Methods("GET")
-	pets.HandleFunc("/{petId}/delete", DeletePet).Methods("DELETE")
+	pets.Handle("", middleware.VerifyToken(http.HandlerFunc(ListPets))).Methods("GET")
+	pets.Handle("/{petId}", middleware.VerifyToken(http.HandlerFunc(GetPet))).Methods("GET")
+	pets.Handle("", middleware.VerifyToken(http.HandlerFunc(CreatePet))).Methods("POST")
+	pets.Handle("/{petId}", middleware.VerifyToken(http.HandlerFunc(UpdatePet))).Methods("PATCH")
+	pets.Handle("/{petId}", middleware.VerifyToken(http.HandlerFunc(DeletePet))).Methods("DELETE")
+	pets.Handle("/{petId}/restore", middleware.VerifyToken(http.HandlerFunc(RestorePet))).Methods("POST")
+	pets.Handle("/{petId}/reserve", middleware.VerifyToken(http.HandlerFunc(ReservePet))).Methods("PATCH")
+	pets.Handle("/{petId}/activate", middleware.VerifyToken(http.HandlerFunc(ActivatePet))).Methods("PATCH")
+	pets.Handle("/{petId}/checkout", middleware.VerifyToken(http.HandlerFunc(CheckOutPet))).Methods("PATCH")
+	pets.Handle("/{petId}/invoice", middleware.VerifyToken(http.HandlerFunc(SendPetInvoice))).Methods("POST")
+	pets.Handle("/{petId}/updates", middleware.VerifyToken(idempotent(http.HandlerFunc(CreatePetUpdate)))).Methods("POST")
+	pets.Handle("/{petId}/updates", middleware.VerifyToken(http.HandlerFunc(GetPetUpdates))).Methods("GET")
+	// Deprecated: kept for older app versions, use DELETE /pets/{petId}
+	pets.Handle("/{petId}/delete", middleware.VerifyToken(http.HandlerFunc(DeletePet))).Methods("DELETE")
+}
+
+// errPetNotFound is returned for missing and soft deleted pets alike
+var errPetNotFound = apperror.NotFound("Pet not found")
+
+// getPet fetches a pet, treating soft deleted pets as missing
+func getPet(ctx context.Context, petId string) (models.Pet, error) {
+	var pet models.Pet
+	doc, err := firestoreClient.Collection("pets").Doc(petId).Get(ctx)
+	if apperror.IsNotFound(err) {
+		return pet, errPetNotFound
+	}
+	if err != nil {
+		return pet, apperror.Internal("Failed to fetch pet", err)
+	}
+	if err := doc.DataTo(&pet); err != nil {
+		return pet, apperror.Internal("Error processing pet data", err)
+	}
+	pet.PetID = petId
+	if pet.Deleted {
+		return pet, errPetNotFound
+	}
+	return pet, nil
+}
+
+// getPetTx fetches a pet inside a transaction, treating soft deleted pets as missing
+func getPetTx(tx *firestore.Transaction, petRef *firestore.DocumentRef) (models.Pet, error) {
+	var pet models.Pet
+	doc, err := tx.Get(petRef)
+	if err != nil {
+		return pet, err
+	}
+	if err := doc.DataTo(&pet); err != nil {
+		return pet, err
+	}
+	pet.PetID = petRef.ID
+	if pet.Deleted {
+		return pet, errPetNotFound
+	}
+	return pet, nil
+}
+
+// authorizePet checks that the caller owns the pet or is staff
+func authorizePet(ctx context.Context, r *http.Request, pet models.Pet) error {
+	uid, _ := r.Context().Value("uid").(string)
+	if pet.OwnerID == uid {
+		return nil
+	}
+	staff, err := isStaff(ctx, r)
+	if err != nil {
+		return err
+	}
+	if !staff {
+		return apperror.Forbidden("You do not have access to this pet")
+	}
+	return nil
 }
 
 // POST /pets
 func CreatePet(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
 	logger.LogInfo("CreatePet called")
 
-	pet := new(models.Pet)
-
-	if err := json.NewDecoder(r.Body).Decode(pet); err != nil {
-		logger.LogErrorf("Failed to decode pet: %v", err)
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	req := new(dto.CreatePetRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode pet: %v", err)
+		apperror.Write(w, r, err)
 		return
 	}
+	// The ID is generated and the owner is always the authenticated caller
+	docRef := firestoreClient.Collection("pets").NewDoc()
+	pet := req.ToModel(docRef.ID, uid, time.Now())
 	logger.LogInfof("Creating pet: %+v", pet)
-	pet.CreatedAt = time.Now()
 
-	// Use provided PetID
-	docRef := firestoreClient.Collection("pets").Doc(pet.PetID)
-
-	// Write the document
-	_, err := docRef.Set(context.Background(), pet)
+	_, err := docRef.Create(r.Context(), pet)
 	duration := time.Since(start)
 	if err != nil {
 		logger.LogErrorf("Failed to save pet: %v", err)
 		logger.LogFirestoreOperation("CREATE", "pets", pet.PetID, false, duration)
-		http.Error(w, "Error saving pet", http.StatusInternalServerError)
+		apperror.Write(w, r, apperror.Internal("Error saving pet", err))
 		return
 	}
 
+	audit(r, "pet.created", "pet", pet.PetID, nil, dto.NewPetResponse(pet))
 	logger.LogInfof("Pet saved with ID: %s", pet.PetID)
 	logger.LogFirestoreOperation("CREATE", "pets", pet.PetID, true, duration)
-	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusCreated, time.Since(start))
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(pet)
+	writeJSON(w, r, http.StatusCreated, dto.NewPetResponse(pet))
 }
 
-// GET /petId
-func GetPet(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
-	petID := mux.Vars(r)["id"]
-	logger.LogInfof("Fetching pet with ID: %s", petID)
+// GET /pets?active=true&status=...&type=dog&limit=100
+// Owners see their own pets, staff see every pet.
+func ListPets(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	q := r.URL.Query()
+	logger.LogInfof("ListPets called by uid: %s with filters %v", uid, q)
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 500 {
+			apperror.Write(w, r, apperror.Validation("Invalid limit"))
+			return
+		}
+		limit = n
+	}
 
-	// Query Firestore for the pet document
-	doc, err := firestoreClient.Collection("pets").Doc(petID).Get(ctx)
+	ctx := r.Context()
+
+	staff, err := isStaff(ctx, r)
 	if err != nil {
-		logger.LogErrorf("Error fetching pet: %v", err)
-		http.Error(w, "Failed to fetch pet", http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
-	// Check if document exists
-	if !doc.Exists() {
-		logger.LogErrorf("Pet not found: %s", petID)
-		http.Error(w, "Pet not found", http.StatusNotFound)
+	query := firestoreClient.Collection("pets").Query
+	if !staff {
+		query = query.Where("ownerId", "==", uid)
+	}
+	if active := q.Get("active"); active != "" {
+		b, err := strconv.ParseBool(active)
+		if err != nil {
+			apperror.Write(w, r, apperror.Validation("Invalid active filter"))
+			return
+		}
+		query = query.Where("active", "==", b)
+	}
+	if status := q.Get("status"); status != "" {
+		query = query.Where("status", "==", status)
+	}
+	if petType := q.Get("type"); petType != "" {
+		query = query.Where("type", "==", petType)
+	}
+
+	// Soft deleted pets are filtered here because older documents lack the deleted field
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	pets := []dto.PetResponse{}
+	for len(pets) < limit {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			logger.LogErrorf("Error listing pets: %v", err)
+			logger.LogFirestoreOperation("READ", "pets", "", false, time.Since(start))
+			apperror.Write(w, r, apperror.Internal("Failed to list pets", err))
+			return
+		}
+		var pet models.Pet
+		if err := doc.DataTo(&pet); err != nil {
+			logger.LogErrorf("Error decoding pet %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if pet.Deleted {
+			continue
+		}
+		pet.PetID = doc.Ref.ID
+		pets = append(pets, dto.NewPetResponse(pet))
+	}
+
+	logger.LogInfof("Listed %d pets for uid: %s", len(pets), uid)
+	logger.LogFirestoreOperation("READ", "pets", "", true, time.Since(start))
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.PetListResponse{Pets: pets, Count: len(pets)})
+}
+
+// PATCH /pets/{petId}
+func UpdatePet(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petId := mux.Vars(r)["petId"]
+	logger.LogInfof("UpdatePet called for petId: %s by uid: %s", petId, uid)
+
+	if ct := r.Header.Get("Content-Type"); ct != "" && strings.HasPrefix(strings.ToLower(ct), "application/merge-patch+json") {
+		// Merge patches are JSON; let the decoder accept the content type
+		r.Header.Set("Content-Type", "application/json")
+	}
+	req := new(dto.UpdatePetRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode pet patch: %v", err)
+		apperror.Write(w, r, err)
 		return
 	}
+	updates := req.Updates()
 
-	// Map the document data to our Pet model
-	var pet models.Pet
-	if err := doc.DataTo(&pet); err != nil {
-		logger.LogErrorf("Error mapping data to pet model: %v", err)
-		http.Error(w, "Error processing pet data", http.StatusInternalServerError)
+	ctx := r.Context()
+
+	var before models.Pet
+	petRef := firestoreClient.Collection("pets").Doc(petId)
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var err error
+		if before, err = getPetTx(tx, petRef); err != nil {
+			return err
+		}
+		if err := authorizePet(ctx, r, before); err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Update(petRef, updates)
+	})
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogFirestoreOperation("UPDATE", "pets", petId, false, duration)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	// Return the pet as it is stored now
+	pet, err := getPet(ctx, petId)
+	if err != nil {
+		apperror.Write(w, r, err)
 		return
 	}
+	if len(updates) > 0 {
+		audit(r, "pet.updated", "pet", petId, dto.NewPetResponse(before), dto.NewPetResponse(pet))
+	}
 
-	// Add the ID to the model
-	pet.PetID = petID
+	logger.LogInfof("Pet %s updated (%d fields)", petId, len(updates))
+	logger.LogFirestoreOperation("UPDATE", "pets", petId, true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewPetResponse(pet))
+}
+
+// GET /pets/{petId}
+func GetPet(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petID := mux.Vars(r)["petId"]
+	logger.LogInfof("Fetching pet with ID: %s for uid: %s", petID, uid)
+
+	ctx := r.Context()
+
+	// Soft deleted pets are reported as missing
+	pet, err := getPet(ctx, petID)
+	if err != nil {
+		logger.LogWarningf("Failed to fetch pet %s: %v", petID, err)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	// Only the owner and staff can see a pet
+	if err := authorizePet(ctx, r, pet); err != nil {
+		logger.LogWarningf("User %s denied access to pet %s", uid, petID)
+		apperror.Write(w, r, err)
+		return
+	}
 
 	// Log success
 	logger.LogInfof("Successfully fetched pet %s in %v", petID, time.Since(startTime))
 
 	// Return the pet as JSON
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(pet); err != nil {
-		logger.LogErrorf("Error encoding pet to JSON: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	writeJSON(w, r, http.StatusOK, dto.NewPetResponse(pet))
+}
+
+// Pet statuses and update captions recorded on reservation, check-in and check-out
+const (
+	reservedStatus   = "Reserved"
+	checkedInStatus  = "Checked in"
+	checkedOutStatus = "Checked out"
+)
+
+// PATCH /pets/{petId}/reserve
+// Books a stay for a pet that is not checked in and emails the owner a confirmation.
+func ReservePet(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petId := mux.Vars(r)["petId"]
+	logger.LogInfof("ReservePet called for petId: %s by uid: %s", petId, uid)
+
+	var req dto.ReservePetRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		logger.LogWarningf("Failed to decode ReservePet body: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	checkInTime, err := time.Parse(time.RFC3339, req.CheckIn)
+	if err != nil {
+		apperror.Write(w, r, apperror.Validation("Invalid checkIn timestamp"))
+		return
+	}
+	checkOutTime, err := time.Parse(time.RFC3339, req.CheckOut)
+	if err != nil {
+		apperror.Write(w, r, apperror.Validation("Invalid checkOut timestamp"))
+		return
+	}
+	if !checkInTime.After(start) {
+		apperror.Write(w, r, apperror.Validation("checkIn must be in the future"))
+		return
+	}
+	if !checkOutTime.After(checkInTime) {
+		apperror.Write(w, r, apperror.Validation("checkOut must be after checkIn"))
+		return
+	}
+
+	ctx := r.Context()
+
+	var before models.Pet
+	petRef := firestoreClient.Collection("pets").Doc(petId)
+	err = firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var err error
+		if before, err = getPetTx(tx, petRef); err != nil {
+			return err
+		}
+		if err := authorizePet(ctx, r, before); err != nil {
+			return err
+		}
+		if before.Active {
+			return apperror.Conflict("Pet is already checked in")
+		}
+		return tx.Update(petRef, []firestore.Update{
+			{Path: "status", Value: reservedStatus},
+			{Path: "checkIn", Value: checkInTime},
+			{Path: "checkOut", Value: checkOutTime},
+		})
+	})
+	duration := time.Since(start)
+	if apperror.IsNotFound(err) {
+		logger.LogWarningf("Pet not found: %s", petId)
+		logger.LogFirestoreOperation("UPDATE", "pets", petId, false, duration)
+		apperror.Write(w, r, errPetNotFound)
+		return
 	}
+	if err != nil {
+		logger.LogErrorf("Failed to reserve pet %s: %v", petId, err)
+		logger.LogFirestoreOperation("UPDATE", "pets", petId, false, duration)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	after := before
+	after.Status, after.CheckIn, after.CheckOut = reservedStatus, checkInTime, checkOutTime
+	audit(r, "pet.reserved", "pet", petId, dto.NewPetResponse(before), dto.NewPetResponse(after))
+	logger.LogInfof("Successfully reserved a stay for pet: %s", petId)
+	logger.LogFirestoreOperation("UPDATE", "pets", petId, true, duration)
+	enqueueJob(r.Context(), JobEmailReservation, petPayload{PetID: petId})
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, time.Since(start))
+	w.WriteHeader(http.StatusNoContent)
 }
 
+// PATCH /pets/{petId}/activate
+// Checks a pet in for its stay. Staff only.
 func ActivatePet(w http.ResponseWriter, r *http.Request) {
+	uid, _ := r.Context().Value("uid").(string)
 	petId := mux.Vars(r)["petId"]
 
-	// 1) Decode JSON payload
-	var req struct {
-		CheckIn  string `json:"checkIn"`
-		CheckOut string `json:"checkOut"`
+	if !requireStaff(r.Context(), w, r) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.LogErrorf("Failed to decode ActivatePet body: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	// 1) Decode JSON payload
+	var req dto.ActivatePetRequest
+
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		logger.LogWarningf("Failed to decode ActivatePet body: %v", err)
+		apperror.Write(w, r, err)
 		return
 	}
-	logger.LogInfof("ActivatePet called for petId=%s, payload=%+v", petId, req)
+	logger.LogInfof("ActivatePet called for petId=%s by uid=%s, payload=%+v", petId, uid, req)
 
 	// 2) Parse ISO-8601 timestamps
 	checkInTime, err := time.Parse(time.RFC3339, req.CheckIn)
 	if err != nil {
 		logger.LogErrorf("Invalid checkIn format: %v", err)
-		http.Error(w, "Invalid checkIn timestamp", http.StatusBadRequest)
+		apperror.Write(w, r, apperror.Validation("Invalid checkIn timestamp"))
 		return
 	}
 	checkOutTime, err := time.Parse(time.RFC3339, req.CheckOut)
 	if err != nil {
 		logger.LogErrorf("Invalid checkOut format: %v", err)
-		http.Error(w, "Invalid checkOut timestamp", http.StatusBadRequest)
+		apperror.Write(w, r, apperror.Validation("Invalid checkOut timestamp"))
+		return
+	}
+	if !checkOutTime.After(checkInTime) {
+		logger.LogErrorf("checkOut %v is not after checkIn %v", checkOutTime, checkInTime)
+		apperror.Write(w, r, apperror.Validation("checkOut must be after checkIn"))
 		return
 	}
 
-	// 3) Perform the Firestore update
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	_, err = firestoreClient.Collection("pets").Doc(petId).Update(ctx, []firestore.Update{
-		{Path: "active", Value: true},
-		{Path: "checkIn", Value: checkInTime},
-		{Path: "checkOut", Value: checkOutTime},
+	// 3) Activate the pet and record the check-in in its history atomically
+	ctx := r.Context()
+
+	var before models.Pet
+	petRef := firestoreClient.Collection("pets").Doc(petId)
+	updateRef := firestoreClient.Collection("pet_updates").NewDoc()
+	err = firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var err error
+		if before, err = getPetTx(tx, petRef); err != nil {
+			return err
+		}
+		if err := tx.Create(updateRef, models.PetUpdate{
+			Caption:   checkedInStatus,
+			PetID:     petId,
+			Timestamp: time.Now(),
+		}); err != nil {
+			return err
+		}
+		return tx.Update(petRef, []firestore.Update{
+			{Path: "active", Value: true},
+			{Path: "status", Value: checkedInStatus},
+			{Path: "checkIn", Value: checkInTime},
+			{Path: "checkOut", Value: checkOutTime},
+		})
 	})
+	if apperror.IsNotFound(err) {
+		logger.LogWarningf("Pet not found: %s", petId)
+		apperror.Write(w, r, apperror.NotFound("Pet not found"))
+		return
+	}
 	if err != nil {
 		logger.LogErrorf("Failed to activate pet: %v", err)
-		http.Error(w, "Failed to activate pet", http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
+	after := before
+	after.Active, after.Status, after.CheckIn, after.CheckOut = true, checkedInStatus, checkInTime, checkOutTime
+	audit(r, "pet.activated", "pet", petId, dto.NewPetResponse(before), dto.NewPetResponse(after))
 	logger.LogInfof("Successfully activated pet: %s", petId)
+	enqueueJob(r.Context(), JobNotifyPetOwner, notifyPetOwnerPayload{
+		PetID: petId,
+		Notification: services.Notification{
+			Type:  services.NotificationPetActivated,
+			Title: "Your pet has checked in",
+			Body:  "Check-out is scheduled for " + checkOutTime.Format("02 Jan 2006 15:04"),
+			Data:  map[string]string{"petId": petId},
+		},
+	})
+	enqueueJob(r.Context(), JobEmailCheckIn, petPayload{PetID: petId})
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// DELETE /pets/{petId}/delete
-func DeletePet(w http.ResponseWriter, r *http.Request) {
+// PATCH /pets/{petId}/checkout
+// Ends the stay of an active pet now, recording the check-out in its history. Staff only.
+func CheckOutPet(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
 	petId := mux.Vars(r)["petId"]
-	logger.LogInfof("DeletePet called for petId: %s", petId)
+	logger.LogInfof("CheckOutPet called for petId: %s by uid: %s", petId, uid)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if !requireStaff(r.Context(), w, r) {
+		return
+	}
+
+	ctx := r.Context()
 
-	_, err := firestoreClient.Collection("pets").Doc(petId).Delete(ctx)
+	// Deactivate the pet and record the check-out in its history atomically
+	var before models.Pet
+	checkOutTime := time.Now()
+	petRef := firestoreClient.Collection("pets").Doc(petId)
+	updateRef := firestoreClient.Collection("pet_updates").NewDoc()
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var err error
+		if before, err = getPetTx(tx, petRef); err != nil {
+			return err
+		}
+		if !before.Active {
+			return apperror.Conflict("Pet is not checked in")
+		}
+		if err := tx.Create(updateRef, models.PetUpdate{
+			Caption:   checkedOutStatus,
+			PetID:     petId,
+			Timestamp: checkOutTime,
+		}); err != nil {
+			return err
+		}
+		return tx.Update(petRef, []firestore.Update{
+			{Path: "active", Value: false},
+			{Path: "status", Value: checkedOutStatus},
+			{Path: "checkOut", Value: checkOutTime},
+		})
+	})
+	duration := time.Since(start)
+	if apperror.IsNotFound(err) {
+		logger.LogWarningf("Pet not found: %s", petId)
+		logger.LogFirestoreOperation("UPDATE", "pets", petId, false, duration)
+		apperror.Write(w, r, errPetNotFound)
+		return
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to check out pet %s: %v", petId, err)
+		logger.LogFirestoreOperation("UPDATE", "pets", petId, false, duration)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	after := before
+	after.Active, after.Status, after.CheckOut = false, checkedOutStatus, checkOutTime
+	audit(r, "pet.checked_out", "pet", petId, dto.NewPetResponse(before), dto.NewPetResponse(after))
+	logger.LogInfof("Successfully checked out pet: %s", petId)
+	logger.LogFirestoreOperation("UPDATE", "pets", petId, true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, time.Since(start))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /pets/{petId}/invoice
+// Emails the pet's owner an invoice for the stay. Staff only.
+func SendPetInvoice(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petId := mux.Vars(r)["petId"]
+	logger.LogInfof("SendPetInvoice called for petId: %s by uid: %s", petId, uid)
+
+	if !requireStaff(r.Context(), w, r) {
+		return
+	}
+
+	var req dto.InvoiceRequest
+	if err := validation.DecodeJSON(w, r, &req); err != nil {
+		logger.LogWarningf("Failed to decode SendPetInvoice body: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	pet, err := getPet(r.Context(), petId)
+	if err != nil {
+		logger.LogWarningf("Failed to get pet %s: %v", petId, err)
+		apperror.Write(w, r, err)
+		return
+	}
+	if pet.OwnerID == "" {
+		apperror.Write(w, r, apperror.Conflict("Pet has no owner"))
+		return
+	}
+
+	invoice := req.ToModel(start)
+	audit(r, "invoice.sent", "pet", petId, nil, map[string]interface{}{
+		"invoiceNumber": invoice.InvoiceNumber,
+		"ownerId":       pet.OwnerID,
+		"total":         invoice.Total,
+	})
+	enqueueJob(r.Context(), JobEmailInvoice, invoicePayload{UID: pet.OwnerID, Invoice: invoice})
+	logger.LogInfof("Invoice %s for pet %s queued", invoice.InvoiceNumber, petId)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusAccepted, time.Since(start))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// DELETE /pets/{petId}
+// DELETE /pets/{petId}/delete (deprecated)
+// Pets are soft deleted so they can be restored; their updates are hidden with them.
+func DeletePet(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petId := mux.Vars(r)["petId"]
+	logger.LogInfof("DeletePet called for petId: %s by uid: %s", petId, uid)
+	if strings.HasSuffix(r.URL.Path, "/delete") {
+		logger.LogWarningf("Deprecated route %s used, clients should call DELETE /pets/{petId}", r.URL.Path)
+	}
+
+	err := setPetDeleted(r, petId, true)
 	duration := time.Since(start)
 	if err != nil {
-		logger.LogErrorf("Failed to delete pet: %v", err)
 		logger.LogFirestoreOperation("DELETE", "pets", petId, false, duration)
-		http.Error(w, "Failed to delete pet", http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
 
-	logger.LogInfof("Successfully deleted pet: %s", petId)
+	audit(r, "pet.deleted", "pet", petId, map[string]interface{}{"deleted": false}, map[string]interface{}{"deleted": true})
+	logger.LogInfof("Successfully deleted pet: %s by uid: %s", petId, uid)
 	logger.LogFirestoreOperation("DELETE", "pets", petId, true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusNoContent, duration)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// POST /pets/{petId}/restore
+func RestorePet(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	petId := mux.Vars(r)["petId"]
+	logger.LogInfof("RestorePet called for petId: %s by uid: %s", petId, uid)
+
+	err := setPetDeleted(r, petId, false)
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogFirestoreOperation("UPDATE", "pets", petId, false, duration)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	pet, err := getPet(ctx, petId)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	audit(r, "pet.restored", "pet", petId, map[string]interface{}{"deleted": true}, map[string]interface{}{"deleted": false})
+	logger.LogInfof("Successfully restored pet: %s by uid: %s", petId, uid)
+	logger.LogFirestoreOperation("UPDATE", "pets", petId, true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewPetResponse(pet))
+}
+
+// setPetDeleted soft deletes or restores a pet owned by the caller (or any pet for staff)
+func setPetDeleted(r *http.Request, petId string, deleted bool) error {
+	ctx := r.Context()
+
+	petRef := firestoreClient.Collection("pets").Doc(petId)
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(petRef)
+		if err != nil {
+			return err
+		}
+		var pet models.Pet
+		if err := doc.DataTo(&pet); err != nil {
+			return err
+		}
+		if pet.Deleted == deleted {
+			if deleted {
+				return errPetNotFound
+			}
+			return apperror.Conflict("Pet is not deleted")
+		}
+		if err := authorizePet(ctx, r, pet); err != nil {
+			return err
+		}
+
+		deletedAt := time.Time{}
+		if deleted {
+			deletedAt = time.Now()
+		}
+		return tx.Update(petRef, []firestore.Update{
+			{Path: "deleted", Value: deleted},
+			{Path: "deletedAt", Value: deletedAt},
+		})
+	})
+	if apperror.IsNotFound(err) {
+		logger.LogWarningf("Pet not found: %s", petId)
+		return errPetNotFound
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to update deleted state of pet %s: %v", petId, err)
+	}
+	return err
+}
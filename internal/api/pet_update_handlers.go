@@ -2,12 +2,15 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"time"
 
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
 	"pawtroli-be/internal/logger"
 	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/validation"
 
 	"cloud.google.com/go/firestore"
 	"github.com/gorilla/mux"
@@ -15,59 +18,98 @@ import (
 )
 
 // POST /pets/{petId}/updates
+// Staff post updates about the pets in their care.
 func CreatePetUpdate(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
 	petId := mux.Vars(r)["petId"]
-	logger.LogInfof("CreatePetUpdate called for petId: %s", petId)
+	logger.LogInfof("CreatePetUpdate called for petId: %s by uid: %s", petId, uid)
 
-	update := new(models.PetUpdate)
-	update.PetID = petId // Set the petId from the URL
-	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
-		logger.LogErrorf("Failed to decode pet update: %v", err)
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+	if !requireStaff(r.Context(), w, r) {
 		return
 	}
-	update.Timestamp = time.Now()
+
+	req := new(dto.CreatePetUpdateRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode pet update: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+	// Always take the petId from the URL
+	update := req.ToModel(petId, time.Now())
 	petStatus := update.Caption
 
-	// 1) Add the pet update
-	_, _, err := firestoreClient.Collection("pet_updates").Add(context.Background(), update)
+	ctx := r.Context()
+
+	// Add the pet update and set the pet's status in one transaction so they never drift apart
+	petRef := firestoreClient.Collection("pets").Doc(petId)
+	updateRef := firestoreClient.Collection("pet_updates").NewDoc()
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := getPetTx(tx, petRef); err != nil {
+			return err
+		}
+		if err := tx.Create(updateRef, update); err != nil {
+			return err
+		}
+		return tx.Update(petRef, []firestore.Update{
+			{Path: "status", Value: petStatus},
+		})
+	})
 	duration := time.Since(start)
+	if apperror.IsNotFound(err) {
+		logger.LogWarningf("Pet not found: %s", petId)
+		apperror.Write(w, r, apperror.NotFound("Pet not found"))
+		return
+	}
 	if err != nil {
 		logger.LogErrorf("Failed to add pet update: %v", err)
 		logger.LogFirestoreOperation("CREATE", "pet_updates", "", false, duration)
-		http.Error(w, "Failed to add update", http.StatusInternalServerError)
+		apperror.Write(w, r, err)
 		return
 	}
-
-	// 2) ALSO update the pet's status field in the pets collection
-	ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel2()
-	_, err = firestoreClient.Collection("pets").Doc(petId).Update(ctx2, []firestore.Update{
-		{Path: "status", Value: petStatus},
-	})
-	if err != nil {
-		logger.LogErrorf("Failed to update pet status: %v", err)
-		// we don't abort the request, we just log it
-	}
+	update.ID = updateRef.ID
+	audit(r, "pet_update.created", "pet_update", update.ID, nil, dto.NewPetUpdateResponse(update))
 
 	logger.LogInfof("Pet update added and status set to %q for petId: %s", petStatus, petId)
-	logger.LogFirestoreOperation("CREATE", "pet_updates", "", true, duration)
+	enqueueJob(r.Context(), JobNotifyPetOwner, notifyPetOwnerPayload{
+		PetID: petId,
+		Notification: services.Notification{
+			Type:  services.NotificationPetUpdate,
+			Title: update.Caption,
+			Body:  update.Description,
+			Data:  map[string]string{"petId": petId},
+		},
+	})
+	logger.LogFirestoreOperation("CREATE", "pet_updates", update.ID, true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusCreated, time.Since(start))
-	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, http.StatusCreated, dto.NewPetUpdateResponse(update))
 }
 
 // GET /pets/{petId}/updates
 func GetPetUpdates(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
 	petId := mux.Vars(r)["petId"]
-	logger.LogInfof("GetPetUpdates called for petId: %s", petId)
+	logger.LogInfof("GetPetUpdates called for petId: %s by uid: %s", petId, uid)
+
+	ctx := r.Context()
+
+	// Updates of a soft deleted pet are hidden with it, and only the owner and staff see them
+	pet, err := getPet(ctx, petId)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+	if err := authorizePet(ctx, r, pet); err != nil {
+		logger.LogWarningf("User %s denied access to updates of pet %s", uid, petId)
+		apperror.Write(w, r, err)
+		return
+	}
 
-	ctx := context.Background()
 	iter := firestoreClient.Collection("pet_updates").Where("petId", "==", petId).Documents(ctx)
 	defer iter.Stop()
 
-	var updates []models.PetUpdate
+	updates := []dto.PetUpdateResponse{}
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
@@ -75,7 +117,7 @@ func GetPetUpdates(w http.ResponseWriter, r *http.Request) {
 		}
 		if err != nil {
 			logger.LogErrorf("Error fetching pet updates: %v", err)
-			http.Error(w, "Failed to fetch updates", http.StatusInternalServerError)
+			apperror.Write(w, r, apperror.Internal("Failed to fetch updates", err))
 			return
 		}
 		var update models.PetUpdate
@@ -84,11 +126,10 @@ func GetPetUpdates(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		update.ID = doc.Ref.ID
-		updates = append(updates, update)
+		updates = append(updates, dto.NewPetUpdateResponse(update))
 		logger.LogInfof("Fetched update: %+v", update)
 	}
 	logger.LogInfof("Fetched %d updates for petId: %s", len(updates), petId)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updates)
+	writeJSON(w, r, http.StatusOK, updates)
 }
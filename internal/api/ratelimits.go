@@ -0,0 +1,22 @@
+package api
+
+import (
+	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/ratelimit"
+)
+
+// RateLimits are the request limits of the API routes, per uid for authenticated routes and
+// per client IP otherwise. Routes that are not listed get the default.
+var RateLimits = middleware.RateLimits{
+	Default: ratelimit.PerMinute(600, 100),
+	Routes: map[string]ratelimit.Limit{
+		"POST /register":                ratelimit.PerMinute(5, 5),
+		"POST /login":                   ratelimit.PerMinute(20, 10),
+		"POST /chats":                   ratelimit.PerMinute(30, 10),
+		"POST /chats/{roomId}/messages": ratelimit.PerMinute(60, 20),
+		"POST /devices":                 ratelimit.PerMinute(20, 10),
+		"GET /admin/audit/export":       ratelimit.PerMinute(5, 2),
+		"GET /admin/logs/search":        ratelimit.PerMinute(20, 5),
+	},
+	AuthFailures: ratelimit.PerMinute(10, 10),
+}
@@ -2,61 +2,111 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/dto"
+	"pawtroli-be/internal/firebase"
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
 	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/validation"
 
 	"cloud.google.com/go/firestore"
+	"firebase.google.com/go/v4/auth"
 	"github.com/gorilla/mux"
 )
 
 func UserRoutes(r *mux.Router) {
-	r.HandleFunc("/register", UserRegister).Methods("POST")
-	r.HandleFunc("/login", UserLogin).Methods("POST")
+	r.Handle("/register", middleware.VerifyToken(http.HandlerFunc(UserRegister))).Methods("POST")
+	r.Handle("/login", middleware.VerifyToken(http.HandlerFunc(UserLogin))).Methods("POST")
+
+	me := r.PathPrefix("/me").Subrouter()
+	me.Handle("", middleware.VerifyToken(http.HandlerFunc(GetProfile))).Methods("GET")
+	me.Handle("", middleware.VerifyToken(http.HandlerFunc(UpdateProfile))).Methods("PATCH")
+	me.Handle("", middleware.VerifyToken(http.HandlerFunc(DeleteAccount))).Methods("DELETE")
 }
 
-// GET /register
+// POST /register
+// The user document ID is always the authenticated uid.
 func UserRegister(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	logger.LogInfo("UserRegister called")
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("UserRegister called for uid: %s", uid)
 
-	user := new(models.User)
-	if err := json.NewDecoder(r.Body).Decode(user); err != nil {
-		logger.LogErrorf("Failed to decode user: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	user := new(dto.RegisterUserRequest)
+	if err := validation.DecodeJSON(w, r, user); err != nil {
+		logger.LogWarningf("Failed to decode user: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+	// The verified email from Firebase Auth wins over the body
+	if email := middleware.TokenEmail(r.Context()); email != "" {
+		user.Email = email
+	}
+	if user.Email == "" {
+		apperror.Write(w, r, apperror.Validation("Validation failed", apperror.FieldError{Field: "email", Message: "is required"}))
+		return
+	}
+	if err := validateMedia("photoUrl", user.PhotoURL, services.UserMediaPrefix(uid)); err != nil {
+		apperror.Write(w, r, err)
 		return
 	}
 	logger.LogInfof("Registering user: %+v", user)
 
-	ctx := context.Background()
-	docRef := firestoreClient.Collection("users").Doc(user.ID)
+	ctx := r.Context()
+	docRef := firestoreClient.Collection("users").Doc(uid)
 
-	_, err := docRef.Set(ctx, map[string]interface{}{
-		"name":      user.Name,
-		"email":     user.Email,
-		"phone":     user.Phone,
-		"role":      user.Role, // "user" or "admin"
-		"createdAt": time.Now(),
-	}, firestore.MergeAll)
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		_, err := tx.Get(docRef)
+		exists := err == nil
+		if err != nil && !apperror.IsNotFound(err) {
+			return err
+		}
+
+		data := map[string]interface{}{
+			"name":     user.Name,
+			"email":    user.Email,
+			"phone":    user.Phone,
+			"language": user.Language,
+			"photoUrl": user.PhotoURL,
+		}
+		// Only the "user" role can be self-registered; re-registering keeps the existing role
+		if !exists {
+			data["role"] = RoleUser
+			data["createdAt"] = time.Now()
+		}
+		return tx.Set(docRef, data, firestore.MergeAll)
+	})
 
 	duration := time.Since(start)
 	if err != nil {
 		logger.LogErrorf("Failed to save user: %v", err)
-		logger.LogFirestoreOperation("CREATE", "users", user.ID, false, duration)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.LogFirestoreOperation("CREATE", "users", uid, false, duration)
+		apperror.Write(w, r, apperror.Internal("Failed to save user", err))
 		return
 	}
 
-	logger.LogInfof("User registered: %s", user.ID)
-	logger.LogFirestoreOperation("CREATE", "users", user.ID, true, duration)
+	// Put the role into the token claims
+	enqueueJob(ctx, JobSyncClaims, userPayload{UID: uid})
+	audit(r, "user.registered", "user", uid, nil, map[string]interface{}{
+		"name":     user.Name,
+		"email":    user.Email,
+		"phone":    user.Phone,
+		"language": user.Language,
+		"photoUrl": user.PhotoURL,
+	})
+
+	logger.LogInfof("User registered: %s", uid)
+	logger.LogFirestoreOperation("CREATE", "users", uid, true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, duration)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	writeJSON(w, r, http.StatusOK, dto.StatusResponse{Status: "ok"})
 }
 
-// UserLogin handles authenticated requests to /login
+// POST /login
 func UserLogin(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	logger.LogInfof("UserLogin called: method=%s, url=%s, remoteAddr=%s",
@@ -67,40 +117,225 @@ func UserLogin(w http.ResponseWriter, r *http.Request) {
 
 	if !ok || uid == "" {
 		logger.LogWarning("Unauthorized access attempt to /login")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apperror.Write(w, r, apperror.Unauthorized("Unauthorized"))
 		return
 	}
 
 	// Fetch user data from Firestore
-	ctx := context.Background()
-	doc, err := firestoreClient.Collection("users").Doc(uid).Get(ctx)
+	ctx := r.Context()
+	user, err := getUser(ctx, uid, middleware.TokenEmail(r.Context()))
 	duration := time.Since(start)
-
+	if apperror.IsNotFound(err) {
+		logger.LogWarningf("User not registered: %s", uid)
+		apperror.Write(w, r, err)
+		return
+	}
 	if err != nil {
 		logger.LogErrorf("Failed to get user data: %v", err)
 		logger.LogFirestoreOperation("READ", "users", uid, false, duration)
-		http.Error(w, "User not found", http.StatusNotFound)
+		apperror.Write(w, r, err)
 		return
 	}
 
-	data := doc.Data()
-	name, _ := data["name"].(string)
-	email, _ := data["email"].(string)
-	phone, _ := data["phone"].(string)
-	role, _ := data["role"].(string)
-
 	logger.LogInfof("Authenticated user: %s", uid)
 	logger.LogFirestoreOperation("READ", "users", uid, true, duration)
 	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
 	logger.LogAuthOperation("login", uid, true)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "authenticated",
-		"uid":    uid,
-		"name":   name,
-		"email":  email,
-		"phone":  phone,
-		"role":   role,
+	writeJSON(w, r, http.StatusOK, dto.LoginResponse{
+		Status:       "authenticated",
+		UserResponse: dto.NewUserResponse(user),
 	})
 }
+
+// getUser fetches a user, keeping the stored email in sync with the Firebase token claims
+func getUser(ctx context.Context, uid, tokenEmail string) (models.User, error) {
+	var user models.User
+	docRef := firestoreClient.Collection("users").Doc(uid)
+	doc, err := docRef.Get(ctx)
+	if apperror.IsNotFound(err) {
+		return user, apperror.NotFound("User not found")
+	}
+	if err != nil {
+		return user, apperror.Internal("Failed to fetch user", err)
+	}
+	if err := doc.DataTo(&user); err != nil {
+		return user, apperror.Internal("Error processing user data", err)
+	}
+	user.ID = uid
+
+	if tokenEmail != "" && tokenEmail != user.Email {
+		logger.LogInfof("Syncing email of user %s from token claims", uid)
+		if _, err := docRef.Update(ctx, []firestore.Update{{Path: "email", Value: tokenEmail}}); err != nil {
+			// The stored email is only a copy, so a failed sync must not fail the request
+			logger.LogErrorf("Failed to sync email of user %s: %v", uid, err)
+		} else {
+			user.Email = tokenEmail
+		}
+	}
+	return user, nil
+}
+
+// GET /me
+func GetProfile(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("GetProfile called for uid: %s", uid)
+
+	ctx := r.Context()
+
+	user, err := getUser(ctx, uid, middleware.TokenEmail(r.Context()))
+	duration := time.Since(start)
+	if err != nil {
+		logger.LogFirestoreOperation("READ", "users", uid, false, duration)
+		apperror.Write(w, r, err)
+		return
+	}
+
+	logger.LogFirestoreOperation("READ", "users", uid, true, duration)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewUserResponse(user))
+}
+
+// PATCH /me
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("UpdateProfile called for uid: %s", uid)
+
+	if ct := r.Header.Get("Content-Type"); ct != "" && strings.HasPrefix(strings.ToLower(ct), "application/merge-patch+json") {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	req := new(dto.UpdateProfileRequest)
+	if err := validation.DecodeJSON(w, r, req); err != nil {
+		logger.LogWarningf("Failed to decode profile patch: %v", err)
+		apperror.Write(w, r, err)
+		return
+	}
+	if req.PhotoURL.Set {
+		if err := validateMedia("photoUrl", req.PhotoURL.Value, services.UserMediaPrefix(uid)); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	user, err := getUser(ctx, uid, middleware.TokenEmail(r.Context()))
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+	oldPhotoURL := user.PhotoURL
+	before := dto.NewUserResponse(user)
+
+	if updates := req.Updates(); len(updates) > 0 {
+		_, err = firestoreClient.Collection("users").Doc(uid).Update(ctx, updates)
+		duration := time.Since(start)
+		if err != nil {
+			logger.LogErrorf("Failed to update profile: %v", err)
+			logger.LogFirestoreOperation("UPDATE", "users", uid, false, duration)
+			apperror.Write(w, r, apperror.Internal("Failed to update profile", err))
+			return
+		}
+		logger.LogFirestoreOperation("UPDATE", "users", uid, true, duration)
+	}
+
+	if req.Name.Set {
+		user.Name = req.Name.Value
+	}
+	if req.Phone.Set {
+		user.Phone = req.Phone.Value
+	}
+	if req.Language.Set {
+		user.Language = req.Language.Value
+	}
+	if req.PhotoURL.Set {
+		user.PhotoURL = req.PhotoURL.Value
+		// Remove the replaced photo from storage; a leftover file is harmless
+		if oldPhotoURL != "" && oldPhotoURL != user.PhotoURL && deletionService != nil {
+			if err := deletionService.DeleteMedia(ctx, oldPhotoURL, services.UserMediaPrefix(uid)); err != nil {
+				logger.LogWarningf("Failed to delete old profile photo of %s: %v", uid, err)
+			}
+		}
+	}
+
+	audit(r, "user.profile_updated", "user", uid, before, dto.NewUserResponse(user))
+	logger.LogInfof("Profile updated for uid: %s", uid)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusOK, time.Since(start))
+	writeJSON(w, r, http.StatusOK, dto.NewUserResponse(user))
+}
+
+// DELETE /me
+// Disables the Firebase Auth account right away and removes or anonymizes the user's
+// data in the background.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	uid, _ := r.Context().Value("uid").(string)
+	logger.LogInfof("DeleteAccount called for uid: %s", uid)
+
+	ctx := r.Context()
+
+	// Queue the deletion before disabling the account, so that a failure leaves the user able
+	// to sign in and ask again. Asking again resumes the same task.
+	task, err := startDeletion(ctx, services.DeletionUser, uid)
+	if err != nil {
+		apperror.Write(w, r, err)
+		return
+	}
+
+	client, err := firebase.App.Auth(ctx)
+	if err != nil {
+		logger.LogErrorf("Failed to get auth client: %v", err)
+		apperror.Write(w, r, apperror.Internal("Failed to get auth client", err))
+		return
+	}
+	if _, err := client.UpdateUser(ctx, uid, (&auth.UserToUpdate{}).Disabled(true)); err != nil {
+		logger.LogErrorf("Failed to disable auth user %s: %v", uid, err)
+		apperror.Write(w, r, apperror.Internal("Failed to disable account", err))
+		return
+	}
+	if err := client.RevokeRefreshTokens(ctx, uid); err != nil {
+		logger.LogWarningf("Failed to revoke refresh tokens of %s: %v", uid, err)
+	}
+	middleware.ForgetAccountState(uid)
+	logger.LogAuthOperation("account_disabled", uid, true)
+
+	audit(r, "user.deletion_requested", "user", uid, nil, map[string]interface{}{"taskId": task.ID})
+	logger.LogInfof("Account deletion of %s queued as task %s", uid, task.ID)
+	logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, http.StatusAccepted, time.Since(start))
+	writeJSON(w, r, http.StatusAccepted, dto.NewDeletionTaskResponse(*task))
+}
+
+// Roles a user can have
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// callerRole returns the role of the authenticated caller, or an empty string for unregistered
+// users. The role normally comes from the token claims; tokens issued before the claim existed
+// fall back to Firestore and the claims are backfilled so the next token carries them.
+func callerRole(ctx context.Context, r *http.Request) (string, error) {
+	if role, ok := middleware.TokenRole(r.Context()); ok {
+		return role, nil
+	}
+	uid, _ := r.Context().Value("uid").(string)
+	doc, err := firestoreClient.Collection("users").Doc(uid).Get(ctx)
+	if apperror.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", apperror.Internal("Failed to fetch user", err)
+	}
+	role, _ := doc.Data()["role"].(string)
+	logger.LogInfof("Token of %s has no role claim, using stored role %q", uid, role)
+	enqueueJob(ctx, JobSyncClaims, userPayload{UID: uid})
+	return role, nil
+}
+
+// isStaff reports whether the caller has the admin role
+func isStaff(ctx context.Context, r *http.Request) (bool, error) {
+	role, err := callerRole(ctx, r)
+	return role == RoleAdmin, err
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// APIVersion is a version of the API, mounted under /<version>
+type APIVersion string
+
+const V1 APIVersion = "v1"
+
+// Versions are the mounted API versions, oldest first
+var Versions = []APIVersion{V1}
+
+// legacyVersion is the version served on the unversioned paths kept for older app releases
+const legacyVersion = V1
+
+// LegacyDeprecation announces the phase-out of the unversioned paths
+var LegacyDeprecation = middleware.Deprecation{
+	Since:  time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+	Sunset: time.Date(2027, 11, 1, 0, 0, 0, 0, time.UTC),
+	Successor: func(r *http.Request) string {
+		return "/" + string(legacyVersion) + r.URL.Path
+	},
+}
+
+type versionKey struct{}
+
+// VersionFromContext returns the API version of the request
+func VersionFromContext(ctx context.Context) APIVersion {
+	v, _ := ctx.Value(versionKey{}).(APIVersion)
+	return v
+}
+
+func withVersion(v APIVersion) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, v)))
+		})
+	}
+}
+
+// legacyRoute is the parent of the unversioned routes, recognized when documenting them
+var legacyRoute *mux.Route
+
+// MountRoutes registers the route groups under every API version, and under the
+// unversioned legacy paths as deprecated aliases of legacyVersion. Route groups register
+// the same handlers in every version; handlers that differ between versions check
+// VersionFromContext, and response bodies are adapted by shapers in writeJSON.
+func MountRoutes(r *mux.Router, groups ...func(*mux.Router)) {
+	for _, v := range Versions {
+		sub := r.PathPrefix("/" + string(v)).Subrouter()
+		sub.Use(withVersion(v))
+		for _, register := range groups {
+			register(sub)
+		}
+	}
+
+	legacyRoute = r.NewRoute()
+	legacy := legacyRoute.Subrouter()
+	legacy.Use(withVersion(legacyVersion), middleware.DeprecationMiddleware(LegacyDeprecation))
+	for _, register := range groups {
+		register(legacy)
+	}
+}
+
+// versionedPath returns path in the API version of r, e.g. /v1/admin/deletions/{id}
+// for requests to /v1 and the unversioned path for legacy requests
+func versionedPath(r *http.Request, path string) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil && middleware.UnversionedTemplate(tmpl) != tmpl {
+			return "/" + string(VersionFromContext(r.Context())) + path
+		}
+	}
+	return path
+}
+
+// shapers adapt response bodies to API versions older than the current shape, keyed by
+// version and the Go type of the body
+var shapers = map[APIVersion]map[reflect.Type]func(interface{}) interface{}{}
+
+// registerShaper makes writeJSON pass bodies of type T through shape for requests to
+// version v, e.g. to keep a renamed field under its old name
+func registerShaper[T any](v APIVersion, shape func(T) interface{}) {
+	if shapers[v] == nil {
+		shapers[v] = map[reflect.Type]func(interface{}) interface{}{}
+	}
+	shapers[v][reflect.TypeFor[T]()] = func(body interface{}) interface{} {
+		return shape(body.(T))
+	}
+}
+
+// writeJSON writes body as the JSON response with the given status, shaped for the API
+// version of the request
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	if shape, ok := shapers[VersionFromContext(r.Context())][reflect.TypeOf(body)]; ok {
+		body = shape(body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		// Headers are already sent, so the error can only be logged
+		logger.LogErrorf("Error encoding response: %v", err)
+	}
+}
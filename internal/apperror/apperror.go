@@ -0,0 +1,208 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kind classifies an error and determines its HTTP status
+type Kind string
+
+const (
+	KindNotFound      Kind = "not_found"
+	KindValidation    Kind = "validation_failed"
+	KindConflict      Kind = "conflict"
+	KindUnauthorized  Kind = "unauthorized"
+	KindForbidden     Kind = "forbidden"
+	KindTooLarge      Kind = "payload_too_large"
+	KindInternal      Kind = "internal"
+	KindUnavailable   Kind = "unavailable"
+	KindTimeout       Kind = "timeout"
+	KindRateLimited   Kind = "rate_limited"
+	KindUnprocessable Kind = "unprocessable"
+)
+
+// httpStatus maps every kind to its HTTP status code
+var httpStatus = map[Kind]int{
+	KindNotFound:      http.StatusNotFound,
+	KindValidation:    http.StatusBadRequest,
+	KindConflict:      http.StatusConflict,
+	KindUnauthorized:  http.StatusUnauthorized,
+	KindForbidden:     http.StatusForbidden,
+	KindTooLarge:      http.StatusRequestEntityTooLarge,
+	KindInternal:      http.StatusInternalServerError,
+	KindUnavailable:   http.StatusServiceUnavailable,
+	KindTimeout:       http.StatusGatewayTimeout,
+	KindRateLimited:   http.StatusTooManyRequests,
+	KindUnprocessable: http.StatusUnprocessableEntity,
+}
+
+// defaultMessage is used when an error is translated without a domain specific message
+var defaultMessage = map[Kind]string{
+	KindNotFound:      "Resource not found",
+	KindValidation:    "Invalid request",
+	KindConflict:      "Request conflicts with the current state of the resource",
+	KindUnauthorized:  "Unauthorized",
+	KindForbidden:     "Forbidden",
+	KindTooLarge:      "Request body too large",
+	KindInternal:      "Internal server error",
+	KindUnavailable:   "Service temporarily unavailable, please retry",
+	KindTimeout:       "Request timed out",
+	KindRateLimited:   "Too many requests",
+	KindUnprocessable: "Request cannot be processed",
+}
+
+// FieldError describes a problem with a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error carrying a client facing message and the underlying cause
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error // underlying cause, never sent to the client
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus returns the HTTP status code of the error
+func (e *Error) HTTPStatus() int {
+	if code, ok := httpStatus[e.Kind]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// NotFound creates an error for a missing resource
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+// Validation creates an error for an invalid request, optionally with per-field details
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// Conflict creates an error for a request that conflicts with the current state
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+// Unauthorized creates an error for a missing or invalid credential
+func Unauthorized(message string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+// Forbidden creates an error for an authenticated caller lacking permission
+func Forbidden(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+// TooLarge creates an error for a request body exceeding the size limit
+func TooLarge(message string) *Error {
+	return &Error{Kind: KindTooLarge, Message: message}
+}
+
+// Unavailable creates an error for a dependency that is temporarily unavailable
+func Unavailable(message string, err error) *Error {
+	return &Error{Kind: KindUnavailable, Message: message, Err: err}
+}
+
+// Timeout creates an error for a request whose deadline expired
+func Timeout(message string, err error) *Error {
+	return &Error{Kind: KindTimeout, Message: message, Err: err}
+}
+
+// RateLimited creates an error for a caller exceeding a rate limit
+func RateLimited(message string) *Error {
+	return &Error{Kind: KindRateLimited, Message: message}
+}
+
+// Unprocessable creates an error for a well-formed request that cannot be processed
+func Unprocessable(message string) *Error {
+	return &Error{Kind: KindUnprocessable, Message: message}
+}
+
+// Internal creates an error for an unexpected failure; err is logged but not exposed
+func Internal(message string, err error) *Error {
+	return &Error{Kind: KindInternal, Message: message, Err: err}
+}
+
+// From converts any error into an *Error. gRPC status codes returned by Firestore and the
+// Google clients are translated to the matching kind, also when wrapped in an internal error.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		if appErr.Kind != KindInternal {
+			return appErr
+		}
+		if kind := kindFromGRPC(appErr.Err); kind != KindInternal {
+			return &Error{Kind: kind, Message: defaultMessage[kind], Err: appErr.Err}
+		}
+		return appErr
+	}
+
+	kind := kindFromGRPC(err)
+	return &Error{Kind: kind, Message: defaultMessage[kind], Err: err}
+}
+
+// Is reports whether err is of the given kind, including translated gRPC status codes
+func Is(err error, kind Kind) bool {
+	if err == nil {
+		return false
+	}
+	return From(err).Kind == kind
+}
+
+// IsNotFound reports whether err means the resource does not exist
+func IsNotFound(err error) bool {
+	return Is(err, KindNotFound)
+}
+
+// kindFromGRPC maps a gRPC status code, or an expired or canceled context, to a kind
+func kindFromGRPC(err error) Kind {
+	if err == nil {
+		return KindInternal
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return KindUnavailable
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return KindNotFound
+	case codes.InvalidArgument, codes.OutOfRange:
+		return KindValidation
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return KindConflict
+	case codes.Unauthenticated:
+		return KindUnauthorized
+	case codes.PermissionDenied:
+		return KindForbidden
+	case codes.DeadlineExceeded:
+		return KindTimeout
+	case codes.Unavailable, codes.Canceled, codes.ResourceExhausted:
+		return KindUnavailable
+	default:
+		return KindInternal
+	}
+}
@@ -0,0 +1,68 @@
+package apperror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pawtroli-be/internal/logger"
+)
+
+// ErrorResponse is the JSON body of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes the error; RequestID identifies the request in the server logs
+type ErrorBody struct {
+	Code      Kind         `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// Write converts err to an *Error and writes it as a JSON error envelope
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := From(err)
+	requestID := logger.RequestIDFromContext(r.Context())
+
+	if appErr.HTTPStatus() >= 500 {
+		logger.LogErrorf("[%s] %s %s failed: %v", logger.RequestTag(r.Context()), r.Method, r.URL.Path, appErr)
+	} else {
+		logger.LogWarningf("[%s] %s %s rejected: %v", logger.RequestTag(r.Context()), r.Method, r.URL.Path, appErr)
+	}
+
+	if appErr.Kind == KindUnavailable {
+		w.Header().Set("Retry-After", "5")
+	}
+	writeEnvelope(w, appErr.HTTPStatus(), ErrorBody{
+		Code:      appErr.Kind,
+		Message:   appErr.Message,
+		Fields:    appErr.Fields,
+		RequestID: requestID,
+	})
+}
+
+func writeEnvelope(w http.ResponseWriter, statusCode int, b ErrorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: b})
+}
+
+// NotFoundHandler responds to unknown routes with a JSON error envelope
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, NotFound("Route not found"))
+	})
+}
+
+// MethodNotAllowedHandler responds to known routes called with the wrong method
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, http.StatusMethodNotAllowed, ErrorBody{
+			Code:      "method_not_allowed",
+			Message:   "Method not allowed",
+			RequestID: logger.RequestIDFromContext(r.Context()),
+		})
+	})
+}
@@ -0,0 +1,134 @@
+// Package certreload serves a TLS certificate from files and reloads it when they change,
+// so renewed certificates are picked up without restarting the server.
+package certreload
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"pawtroli-be/internal/logger"
+)
+
+// Reloader holds the certificate loaded from a certificate and key file pair
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewReloader loads the certificate and checks the files for changes every interval once
+// started
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	cr := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// latestModTime returns the most recent modification time of the two files
+func (cr *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload loads the certificate, keeping the current one if the files are invalid, e.g.
+// because only one of them has been replaced so far
+func (cr *Reloader) reload() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %v", err)
+	}
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, for use as tls.Config.GetCertificate
+func (cr *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// TLSConfig returns a server TLS configuration serving the current certificate over
+// HTTP/2 and HTTP/1.1
+func (cr *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// Start checks the files for changes in the background
+func (cr *Reloader) Start() {
+	cr.wg.Add(1)
+	go func() {
+		defer cr.wg.Done()
+		ticker := time.NewTicker(cr.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				modTime, err := cr.latestModTime()
+				if err != nil {
+					logger.LogWarningf("Failed to check TLS certificate: %v", err)
+					continue
+				}
+				cr.mu.RLock()
+				changed := !modTime.Equal(cr.modTime)
+				cr.mu.RUnlock()
+				if !changed {
+					continue
+				}
+				if err := cr.reload(); err != nil {
+					logger.LogWarningf("Keeping previous TLS certificate: %v", err)
+					continue
+				}
+				logger.LogInfof("Reloaded TLS certificate from %s", cr.certFile)
+			case <-cr.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops checking for changes. It is safe to call more than once.
+func (cr *Reloader) Stop() {
+	cr.stopOnce.Do(func() {
+		close(cr.stopChan)
+		cr.wg.Wait()
+	})
+}
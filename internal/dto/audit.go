@@ -0,0 +1,42 @@
+package dto
+
+import "pawtroli-be/internal/models"
+
+// AuditEntryResponse is an audit log entry as returned to admins
+type AuditEntryResponse struct {
+	ID           string                 `json:"id"`
+	ActorUID     string                 `json:"actorUid"`
+	ActorRole    string                 `json:"actorRole"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resourceType"`
+	ResourceID   string                 `json:"resourceId"`
+	Before       map[string]interface{} `json:"before,omitempty"`
+	After        map[string]interface{} `json:"after,omitempty"`
+	RequestID    string                 `json:"requestId"`
+	IP           string                 `json:"ip"`
+	Timestamp    string                 `json:"timestamp"`
+}
+
+// NewAuditEntryResponse maps a stored audit entry to its response
+func NewAuditEntryResponse(e models.AuditEntry) AuditEntryResponse {
+	return AuditEntryResponse{
+		ID:           e.ID,
+		ActorUID:     e.ActorUID,
+		ActorRole:    e.ActorRole,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		Before:       e.Before,
+		After:        e.After,
+		RequestID:    e.RequestID,
+		IP:           e.IP,
+		Timestamp:    FormatTime(e.Timestamp),
+	}
+}
+
+// AuditListResponse is a page of audit entries; NextCursor is empty on the last page
+type AuditListResponse struct {
+	Entries    []AuditEntryResponse `json:"entries"`
+	Count      int                  `json:"count"`
+	NextCursor string               `json:"nextCursor"`
+}
@@ -0,0 +1,82 @@
+package dto
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"pawtroli-be/internal/models"
+)
+
+// CreateChatRoomRequest is the body of POST /chats
+type CreateChatRoomRequest struct {
+	UserIDs []string `json:"userIds" validate:"required,min=2,max=20,dive,required,docid"`
+}
+
+// RoomID returns the deterministic room ID of the participants, so the same
+// participants always share one room regardless of order
+func (req CreateChatRoomRequest) RoomID() string {
+	ids := append([]string(nil), req.UserIDs...)
+	sort.Strings(ids)
+	return strings.Join(ids, "_")
+}
+
+// ToModel maps the request to a new chat room
+func (req CreateChatRoomRequest) ToModel(now time.Time) models.ChatRoom {
+	return models.ChatRoom{
+		ID:        req.RoomID(),
+		UserIDs:   req.UserIDs,
+		CreatedAt: now,
+	}
+}
+
+// ChatRoomResponse is a chat room as returned by the API
+type ChatRoomResponse struct {
+	ID        string   `json:"id"`
+	UserIDs   []string `json:"userIds"`
+	CreatedAt string   `json:"createdAt,omitempty"`
+}
+
+// NewChatRoomResponse maps a stored chat room to its response
+func NewChatRoomResponse(room models.ChatRoom) ChatRoomResponse {
+	return ChatRoomResponse{
+		ID:        room.ID,
+		UserIDs:   room.UserIDs,
+		CreatedAt: FormatTime(room.CreatedAt),
+	}
+}
+
+// SendMessageRequest is the body of POST /chats/{roomId}/messages
+type SendMessageRequest struct {
+	Content string `json:"content" validate:"required,max=4000"`
+}
+
+// ToModel maps the request to a new message sent by senderID
+func (req SendMessageRequest) ToModel(roomID, senderID string, now time.Time) models.Message {
+	return models.Message{
+		RoomID:    roomID,
+		SenderID:  senderID,
+		Content:   req.Content,
+		Timestamp: now,
+	}
+}
+
+// MessageResponse is a chat message as returned by the API
+type MessageResponse struct {
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	SenderID  string `json:"senderId"`
+	RoomID    string `json:"roomId"`
+	Timestamp string `json:"timestamp"`
+}
+
+// NewMessageResponse maps a stored message to its response
+func NewMessageResponse(m models.Message) MessageResponse {
+	return MessageResponse{
+		ID:        m.ID,
+		Content:   m.Content,
+		SenderID:  m.SenderID,
+		RoomID:    m.RoomID,
+		Timestamp: FormatTime(m.Timestamp),
+	}
+}
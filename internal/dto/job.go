@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"pawtroli-be/internal/models"
+)
+
+// JobResponse is a background job as returned by the admin API
+type JobResponse struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	RunAt       string          `json:"runAt,omitempty"`
+	LastError   string          `json:"lastError,omitempty"`
+	CreatedAt   string          `json:"createdAt,omitempty"`
+	UpdatedAt   string          `json:"updatedAt,omitempty"`
+}
+
+// NewJobResponse maps a stored job to its response
+func NewJobResponse(j models.Job) JobResponse {
+	payload := json.RawMessage(j.Payload)
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(j.Payload)
+	}
+	return JobResponse{
+		ID:          j.ID,
+		Type:        j.Type,
+		Payload:     payload,
+		Status:      j.Status,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		RunAt:       FormatTime(j.RunAt),
+		LastError:   j.LastError,
+		CreatedAt:   FormatTime(j.CreatedAt),
+		UpdatedAt:   FormatTime(j.UpdatedAt),
+	}
+}
+
+// JobListResponse is the body of GET /admin/jobs
+type JobListResponse struct {
+	Jobs  []JobResponse `json:"jobs"`
+	Count int           `json:"count"`
+}
+
+// DeletionTaskResponse is a deletion task and its progress as returned by the API
+type DeletionTaskResponse struct {
+	ID          string         `json:"id"`
+	Kind        string         `json:"kind"`
+	TargetID    string         `json:"targetId"`
+	Status      string         `json:"status"`
+	Deleted     map[string]int `json:"deleted"`
+	Anonymized  map[string]int `json:"anonymized"`
+	LastError   string         `json:"lastError,omitempty"`
+	CreatedAt   string         `json:"createdAt,omitempty"`
+	UpdatedAt   string         `json:"updatedAt,omitempty"`
+	CompletedAt string         `json:"completedAt,omitempty"`
+}
+
+// NewDeletionTaskResponse maps a stored deletion task to its response
+func NewDeletionTaskResponse(t models.DeletionTask) DeletionTaskResponse {
+	return DeletionTaskResponse{
+		ID:          t.ID,
+		Kind:        t.Kind,
+		TargetID:    t.TargetID,
+		Status:      t.Status,
+		Deleted:     t.Deleted,
+		Anonymized:  t.Anonymized,
+		LastError:   t.LastError,
+		CreatedAt:   FormatTime(t.CreatedAt),
+		UpdatedAt:   FormatTime(t.UpdatedAt),
+		CompletedAt: FormatTime(t.CompletedAt),
+	}
+}
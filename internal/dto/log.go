@@ -0,0 +1,26 @@
+package dto
+
+import "pawtroli-be/internal/services"
+
+// LogFileListResponse is the body of GET /admin/logs
+type LogFileListResponse struct {
+	Files []services.LogFileInfo `json:"files"`
+	Count int                    `json:"count"`
+}
+
+// LogLinesResponse is a range of lines of a log file starting at line From
+type LogLinesResponse struct {
+	File    string             `json:"file"`
+	From    int                `json:"from"`
+	Lines   []services.LogLine `json:"lines"`
+	Count   int                `json:"count"`
+	HasMore bool               `json:"hasMore"`
+}
+
+// LogSearchResponse holds the lines matching a log search; Truncated is set when the
+// limit was reached
+type LogSearchResponse struct {
+	Results   []services.LogSearchResult `json:"results"`
+	Count     int                        `json:"count"`
+	Truncated bool                       `json:"truncated"`
+}
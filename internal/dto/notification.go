@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"time"
+
+	"pawtroli-be/internal/models"
+)
+
+// RegisterDeviceRequest is the body of POST /devices
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" validate:"required,docid,max=4096"`
+	Platform string `json:"platform" validate:"oneof=android ios web"`
+}
+
+// ToModel maps the request to a device token registered now
+func (req RegisterDeviceRequest) ToModel(now time.Time) models.DeviceToken {
+	return models.DeviceToken{
+		Token:     req.Token,
+		Platform:  req.Platform,
+		CreatedAt: now,
+	}
+}
+
+// NotificationPreferencesRequest is the body of PUT /notifications/preferences
+type NotificationPreferencesRequest struct {
+	PetUpdates    bool `json:"petUpdates"`
+	ChatMessages  bool `json:"chatMessages"`
+	PetActivation bool `json:"petActivation"`
+}
+
+// ToModel maps the request to stored preferences
+func (req NotificationPreferencesRequest) ToModel() models.NotificationPreferences {
+	return models.NotificationPreferences{
+		PetUpdates:    req.PetUpdates,
+		ChatMessages:  req.ChatMessages,
+		PetActivation: req.PetActivation,
+	}
+}
+
+// NotificationPreferencesResponse is a user's notification preferences as returned by the API
+type NotificationPreferencesResponse struct {
+	PetUpdates    bool `json:"petUpdates"`
+	ChatMessages  bool `json:"chatMessages"`
+	PetActivation bool `json:"petActivation"`
+}
+
+// NewNotificationPreferencesResponse maps stored preferences to their response
+func NewNotificationPreferencesResponse(p models.NotificationPreferences) NotificationPreferencesResponse {
+	return NotificationPreferencesResponse{
+		PetUpdates:    p.PetUpdates,
+		ChatMessages:  p.ChatMessages,
+		PetActivation: p.PetActivation,
+	}
+}
@@ -0,0 +1,27 @@
+package dto
+
+import "encoding/json"
+
+// PatchField is a field of a JSON merge-patch (RFC 7396) body. Absent fields are left
+// unchanged, null clears the field and any other value replaces it.
+type PatchField[T any] struct {
+	Set   bool // the field was present in the body
+	Null  bool // the field was explicitly null
+	Value T
+}
+
+func (f *PatchField[T]) UnmarshalJSON(b []byte) error {
+	f.Set = true
+	if string(b) == "null" {
+		f.Null = true
+		var zero T
+		f.Value = zero
+		return nil
+	}
+	return json.Unmarshal(b, &f.Value)
+}
+
+// OptionalValue returns the value to validate and whether the field was present
+func (f PatchField[T]) OptionalValue() (interface{}, bool) {
+	return f.Value, f.Set
+}
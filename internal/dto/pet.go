@@ -0,0 +1,207 @@
+package dto
+
+import (
+	"time"
+
+	"pawtroli-be/internal/models"
+	"pawtroli-be/internal/services"
+
+	"cloud.google.com/go/firestore"
+)
+
+// CreatePetRequest is the body of POST /pets
+type CreatePetRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	Type     string `json:"type" validate:"required,oneof=dog cat rabbit bird hamster other"`
+	Gender   string `json:"gender" validate:"required,oneof=male female"`
+	Age      int    `json:"age" validate:"min=0,max=40"`
+	Color    string `json:"color" validate:"max=50"`
+	Allergy  string `json:"allergy" validate:"max=500"`
+	Other    string `json:"other" validate:"max=1000"`
+	ImageURL string `json:"imageUrl" validate:"url,max=2048"`
+}
+
+// ToModel maps the request to a new pet owned by ownerID. Server controlled fields
+// (ID, owner, activation, status and timestamps) are never taken from the request.
+func (req CreatePetRequest) ToModel(petID, ownerID string, now time.Time) models.Pet {
+	return models.Pet{
+		PetID:     petID,
+		Name:      req.Name,
+		Type:      req.Type,
+		Gender:    req.Gender,
+		Age:       req.Age,
+		Color:     req.Color,
+		Allergy:   req.Allergy,
+		Other:     req.Other,
+		ImageURL:  req.ImageURL,
+		OwnerID:   ownerID,
+		CreatedAt: now,
+	}
+}
+
+// UpdatePetRequest is the JSON merge-patch body of PATCH /pets/{petId}. Only the
+// editable fields can be changed; required fields cannot be cleared.
+type UpdatePetRequest struct {
+	Name     PatchField[string] `json:"name" validate:"required,max=50"`
+	Type     PatchField[string] `json:"type" validate:"required,oneof=dog cat rabbit bird hamster other"`
+	Gender   PatchField[string] `json:"gender" validate:"required,oneof=male female"`
+	Age      PatchField[int]    `json:"age" validate:"min=0,max=40"`
+	Color    PatchField[string] `json:"color" validate:"max=50"`
+	Allergy  PatchField[string] `json:"allergy" validate:"max=500"`
+	Other    PatchField[string] `json:"other" validate:"max=1000"`
+	ImageURL PatchField[string] `json:"imageUrl" validate:"url,max=2048"`
+}
+
+// Updates returns the Firestore updates for the fields present in the patch
+func (req UpdatePetRequest) Updates() []firestore.Update {
+	var updates []firestore.Update
+	add := func(path string, set bool, value interface{}) {
+		if set {
+			updates = append(updates, firestore.Update{Path: path, Value: value})
+		}
+	}
+	add("name", req.Name.Set, req.Name.Value)
+	add("type", req.Type.Set, req.Type.Value)
+	add("gender", req.Gender.Set, req.Gender.Value)
+	add("age", req.Age.Set, req.Age.Value)
+	add("color", req.Color.Set, req.Color.Value)
+	add("allergy", req.Allergy.Set, req.Allergy.Value)
+	add("other", req.Other.Set, req.Other.Value)
+	add("imageUrl", req.ImageURL.Set, req.ImageURL.Value)
+	return updates
+}
+
+// ActivatePetRequest is the body of PATCH /pets/{petId}/activate
+type ActivatePetRequest struct {
+	CheckIn  string `json:"checkIn" validate:"required,rfc3339"`
+	CheckOut string `json:"checkOut" validate:"required,rfc3339"`
+}
+
+// ReservePetRequest is the body of PATCH /pets/{petId}/reserve
+type ReservePetRequest struct {
+	CheckIn  string `json:"checkIn" validate:"required,rfc3339"`
+	CheckOut string `json:"checkOut" validate:"required,rfc3339"`
+}
+
+// InvoiceItemRequest is a single line of an InvoiceRequest
+type InvoiceItemRequest struct {
+	Description string `json:"description" validate:"required,max=200"`
+	Quantity    int    `json:"quantity" validate:"min=1,max=1000"`
+	Amount      int64  `json:"amount" validate:"min=0,max=1000000000"` // in rupiah, for the whole line
+}
+
+// InvoiceRequest is the body of POST /pets/{petId}/invoice
+type InvoiceRequest struct {
+	InvoiceNumber string               `json:"invoiceNumber" validate:"required,max=50"`
+	DueAt         string               `json:"dueAt" validate:"rfc3339"`
+	Items         []InvoiceItemRequest `json:"items" validate:"required,min=1,max=100,dive"`
+}
+
+// ToModel maps the request to the invoice email issued now, totalling its items. DueAt must
+// have been validated.
+func (req InvoiceRequest) ToModel(now time.Time) services.InvoiceEmailData {
+	invoice := services.InvoiceEmailData{
+		InvoiceNumber: req.InvoiceNumber,
+		IssuedAt:      now,
+	}
+	if req.DueAt != "" {
+		invoice.DueAt, _ = time.Parse(time.RFC3339, req.DueAt)
+	}
+	for _, item := range req.Items {
+		invoice.Items = append(invoice.Items, services.InvoiceItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			Amount:      item.Amount,
+		})
+		invoice.Total += item.Amount
+	}
+	return invoice
+}
+
+// PetResponse is a pet as returned by the API
+type PetResponse struct {
+	PetID     string `json:"petId"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Gender    string `json:"gender"`
+	Age       int    `json:"age"`
+	Color     string `json:"color"`
+	Allergy   string `json:"allergy"`
+	Other     string `json:"other"`
+	OwnerID   string `json:"ownerId"`
+	ImageURL  string `json:"imageUrl"`
+	Active    bool   `json:"active"`
+	Status    string `json:"status"`
+	CheckIn   string `json:"checkIn,omitempty"`
+	CheckOut  string `json:"checkOut,omitempty"`
+	CreatedAt string `json:"createdAt,omitempty"`
+	DeletedAt string `json:"deletedAt,omitempty"`
+}
+
+// NewPetResponse maps a stored pet to its response
+func NewPetResponse(p models.Pet) PetResponse {
+	return PetResponse{
+		PetID:     p.PetID,
+		Name:      p.Name,
+		Type:      p.Type,
+		Gender:    p.Gender,
+		Age:       p.Age,
+		Color:     p.Color,
+		Allergy:   p.Allergy,
+		Other:     p.Other,
+		OwnerID:   p.OwnerID,
+		ImageURL:  p.ImageURL,
+		Active:    p.Active,
+		Status:    p.Status,
+		CheckIn:   FormatTime(p.CheckIn),
+		CheckOut:  FormatTime(p.CheckOut),
+		CreatedAt: FormatTime(p.CreatedAt),
+		DeletedAt: FormatTime(p.DeletedAt),
+	}
+}
+
+// CreatePetUpdateRequest is the body of POST /pets/{petId}/updates
+type CreatePetUpdateRequest struct {
+	Caption     string `json:"caption" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=2000"`
+	ImageURL    string `json:"imageUrl" validate:"url,max=2048"`
+}
+
+// ToModel maps the request to a new update of the given pet
+func (req CreatePetUpdateRequest) ToModel(petID string, now time.Time) models.PetUpdate {
+	return models.PetUpdate{
+		Caption:     req.Caption,
+		Description: req.Description,
+		ImageURL:    req.ImageURL,
+		PetID:       petID,
+		Timestamp:   now,
+	}
+}
+
+// PetUpdateResponse is a pet update as returned by the API
+type PetUpdateResponse struct {
+	ID          string `json:"id"`
+	PetID       string `json:"petId"`
+	Caption     string `json:"caption"`
+	Description string `json:"description"`
+	ImageURL    string `json:"imageUrl"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// NewPetUpdateResponse maps a stored pet update to its response
+func NewPetUpdateResponse(u models.PetUpdate) PetUpdateResponse {
+	return PetUpdateResponse{
+		ID:          u.ID,
+		PetID:       u.PetID,
+		Caption:     u.Caption,
+		Description: u.Description,
+		ImageURL:    u.ImageURL,
+		Timestamp:   FormatTime(u.Timestamp),
+	}
+}
+
+// PetListResponse is the body of GET /pets
+type PetListResponse struct {
+	Pets  []PetResponse `json:"pets"`
+	Count int           `json:"count"`
+}
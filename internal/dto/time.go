@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+// jakarta is the time zone timestamps are rendered in (UTC+7)
+var jakarta = loadJakarta()
+
+func loadJakarta() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// FormatTime renders t as an RFC 3339 timestamp in UTC+7, or an empty string for the zero time
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(jakarta).Format(time.RFC3339)
+}
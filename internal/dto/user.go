@@ -0,0 +1,124 @@
+package dto
+
+import (
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// RegisterUserRequest is the body of POST /register. The user ID comes from the token and
+// the email from its claims; the body email is only used when the token has none.
+type RegisterUserRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"email,max=254"`
+	Phone    string `json:"phone" validate:"e164"`
+	Language string `json:"language" validate:"oneof=id en"`
+	PhotoURL string `json:"photoUrl" validate:"url,max=2048"`
+}
+
+// UpdateProfileRequest is the JSON merge-patch body of PATCH /me. The email is managed by
+// Firebase Auth and the role by admins, so neither can be changed here.
+type UpdateProfileRequest struct {
+	Name     PatchField[string] `json:"name" validate:"required,max=100"`
+	Phone    PatchField[string] `json:"phone" validate:"e164"`
+	Language PatchField[string] `json:"language" validate:"oneof=id en"`
+	PhotoURL PatchField[string] `json:"photoUrl" validate:"url,max=2048"`
+}
+
+// Updates returns the Firestore updates for the fields present in the patch
+func (req UpdateProfileRequest) Updates() []firestore.Update {
+	var updates []firestore.Update
+	add := func(path string, set bool, value interface{}) {
+		if set {
+			updates = append(updates, firestore.Update{Path: path, Value: value})
+		}
+	}
+	add("name", req.Name.Set, req.Name.Value)
+	add("phone", req.Phone.Set, req.Phone.Value)
+	add("language", req.Language.Set, req.Language.Value)
+	add("photoUrl", req.PhotoURL.Set, req.PhotoURL.Value)
+	return updates
+}
+
+// UserResponse is a user as returned by the API
+type UserResponse struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	Role      string `json:"role"`
+	Branch    string `json:"branch,omitempty"`
+	Language  string `json:"language,omitempty"`
+	PhotoURL  string `json:"photoUrl,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// LoginResponse is the response of POST /login
+type LoginResponse struct {
+	Status string `json:"status"`
+	UserResponse
+}
+
+// NewUserResponse maps a stored user to its response
+func NewUserResponse(u models.User) UserResponse {
+	return UserResponse{
+		UID:       u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Phone:     u.Phone,
+		Role:      u.Role,
+		Branch:    u.Branch,
+		Language:  u.Language,
+		PhotoURL:  u.PhotoURL,
+		Disabled:  u.Disabled,
+		CreatedAt: FormatTime(u.CreatedAt),
+	}
+}
+
+// ChangeRoleRequest is the body of PUT /admin/users/{uid}/role
+type ChangeRoleRequest struct {
+	Role   string `json:"role" validate:"required,oneof=user admin"`
+	Branch string `json:"branch" validate:"docid,max=128"`
+}
+
+// StayResponse is a pet's current or last stay
+type StayResponse struct {
+	PetID    string `json:"petId"`
+	PetName  string `json:"petName"`
+	Active   bool   `json:"active"`
+	Status   string `json:"status"`
+	CheckIn  string `json:"checkIn"`
+	CheckOut string `json:"checkOut"`
+}
+
+// NewStayResponse maps a pet's check-in details to a stay
+func NewStayResponse(p models.Pet) StayResponse {
+	return StayResponse{
+		PetID:    p.PetID,
+		PetName:  p.Name,
+		Active:   p.Active,
+		Status:   p.Status,
+		CheckIn:  FormatTime(p.CheckIn),
+		CheckOut: FormatTime(p.CheckOut),
+	}
+}
+
+// AdminUserResponse is a user with their pets and stays, as returned to admins
+type AdminUserResponse struct {
+	User  UserResponse   `json:"user"`
+	Pets  []PetResponse  `json:"pets"`
+	Stays []StayResponse `json:"stays"`
+}
+
+// UserListResponse is a page of users; NextCursor is empty on the last page
+type UserListResponse struct {
+	Users      []UserResponse `json:"users"`
+	Count      int            `json:"count"`
+	NextCursor string         `json:"nextCursor"`
+}
+
+// StatusResponse acknowledges a request that has no other result
+type StatusResponse struct {
+	Status string `json:"status"`
+}
@@ -1,12 +1,18 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"pawtroli-be/internal/metrics"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -14,9 +20,13 @@ var (
 	ErrorLogger   *log.Logger
 	WarningLogger *log.Logger
 	DebugLogger   *log.Logger
-	logFile       *os.File
+	output        = &fileWriter{maxFileSize: 100 << 20}
+	initOnce      sync.Once
 )
 
+// logDir is the directory log files are written to
+const logDir = "logs"
+
 // LogLevel represents different log levels
 type LogLevel int
 
@@ -27,41 +37,99 @@ const (
 	ERROR
 )
 
-// InitLogger initializes the logging system with file and console output
+// SetMaxFileSize sets the size after which RotateLogFile starts a new file for the day.
+// A size of zero or less disables size-based rotation.
+func SetMaxFileSize(size int64) {
+	output.setMaxFileSize(size)
+}
+
+// LogFileName returns the name of the log file for a date; index 0 is the first file of
+// the day and later indexes are the files started when it grew past the maximum size
+func LogFileName(date string, index int) string {
+	if index == 0 {
+		return fmt.Sprintf("pawtroli_%s.log", date)
+	}
+	return fmt.Sprintf("pawtroli_%s.%d.log", date, index)
+}
+
+// CurrentLogFile returns the name of the log file currently written to
+func CurrentLogFile() string {
+	return output.name()
+}
+
+// InitLogger initializes the logging system with file and console output. The loggers are
+// created once; calling it again reopens the log file.
 func InitLogger() error {
 	// Create log directory if it doesn't exist
-	logDir := "logs"
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %v", err)
 	}
 
-	// Create log filename with current date
-	logFileName := fmt.Sprintf("pawtroli_%s.log", time.Now().Format("2006-01-02"))
-	logFilePath := filepath.Join(logDir, logFileName)
-
-	// Open log file for writing (create if not exists, append if exists)
-	var err error
-	logFile, err = os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
+	date := time.Now().Format("2006-01-02")
+	if err := output.open(date, nextLogIndex(date)); err != nil {
+		return err
 	}
 
-	// Create multi-writers to write to both file and console
-	infoWriter := io.MultiWriter(os.Stdout, logFile)
-	errorWriter := io.MultiWriter(os.Stderr, logFile)
-	warningWriter := io.MultiWriter(os.Stdout, logFile)
-	debugWriter := io.MultiWriter(os.Stdout, logFile)
+	initOnce.Do(func() {
+		// Write to both console and file unless sinks were configured before
+		sinksMu.Lock()
+		if sinks == nil {
+			sinks = []Sink{NewConsoleSink(DEBUG, false), FileSink(DEBUG)}
+		}
+		sinksMu.Unlock()
 
-	// Initialize loggers with different prefixes and flags
-	InfoLogger = log.New(infoWriter, "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(errorWriter, "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
-	WarningLogger = log.New(warningWriter, "[WARNING] ", log.Ldate|log.Ltime|log.Lshortfile)
-	DebugLogger = log.New(debugWriter, "[DEBUG] ", log.Ldate|log.Ltime|log.Lshortfile)
+		// Initialize loggers with different prefixes and flags
+		InfoLogger = log.New(levelWriter(INFO), "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
+		ErrorLogger = log.New(levelWriter(ERROR), "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
+		WarningLogger = log.New(levelWriter(WARNING), "[WARNING] ", log.Ldate|log.Ltime|log.Lshortfile)
+		DebugLogger = log.New(levelWriter(DEBUG), "[DEBUG] ", log.Ldate|log.Ltime|log.Lshortfile)
+	})
 
 	InfoLogger.Println("Logger initialized successfully")
 	return nil
 }
 
+// nextLogIndex returns the index of the file to write for a date: today's latest file,
+// unless it has already been rotated and compressed
+func nextLogIndex(date string) int {
+	index, compressed := latestLogIndex(date)
+	if compressed {
+		index++
+	}
+	return index
+}
+
+// latestLogIndex returns the highest index of the log files of a date, and whether that
+// file has already been compressed
+func latestLogIndex(date string) (int, bool) {
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return 0, false
+	}
+	latest, compressed := 0, false
+	prefix := "pawtroli_" + date
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		gz := strings.HasSuffix(name, ".log.gz")
+		rest := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ".log")
+		index := 0
+		if rest != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(rest, "."))
+			if err != nil || !strings.HasPrefix(rest, ".") {
+				continue
+			}
+			index = n
+		}
+		if index > latest || (index == latest && gz) {
+			latest, compressed = index, gz
+		}
+	}
+	return latest, compressed
+}
+
 // LogInfo logs info level messages
 func LogInfo(v ...interface{}) {
 	if InfoLogger != nil {
@@ -126,6 +194,7 @@ func LogHTTPRequest(method, path, remoteAddr string, statusCode int, duration ti
 
 // LogFirestoreOperation logs Firestore operation details
 func LogFirestoreOperation(operation, collection, docID string, success bool, duration time.Duration) {
+	metrics.ObserveFirestoreOperation(operation, collection, success, duration)
 	if success {
 		LogInfof("Firestore %s operation on %s/%s successful - Duration: %v",
 			operation, collection, docID, duration)
@@ -144,22 +213,52 @@ func LogAuthOperation(operation, uid string, success bool) {
 	}
 }
 
-// CloseLogger closes the log file
+// CloseLogger flushes and closes the sinks, including the log file
 func CloseLogger() {
-	if logFile != nil {
-		logFile.Close()
+	closeSinks()
+}
+
+// RotateLogFile starts a new log file if it's a new day or the current file has grown
+// past the maximum size, and reports whether it did. It is safe to call while other
+// goroutines are logging.
+func RotateLogFile() (bool, error) {
+	previous, err := output.rotate(time.Now().Format("2006-01-02"))
+	if err != nil || previous == "" {
+		return false, err
 	}
+	LogInfof("Rotated log file %s", previous)
+	return true, nil
 }
 
-// RotateLogFile rotates the log file if it's a new day
-func RotateLogFile() error {
-	newLogFileName := fmt.Sprintf("pawtroli_%s.log", time.Now().Format("2006-01-02"))
-	currentLogFileName := filepath.Base(logFile.Name())
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
 
-	if newLogFileName != currentLogFileName {
-		LogInfo("Rotating log file...")
-		CloseLogger()
-		return InitLogger()
+// ContextWithRequestID returns a copy of ctx carrying the request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// TraceIDFromContext returns the ID of the trace of the span in ctx, or an empty string
+func TraceIDFromContext(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
 	}
-	return nil
+	return spanContext.TraceID().String()
+}
+
+// RequestTag identifies a request in log lines by its request ID and, when traced, its
+// trace ID, e.g. "3f2a... trace=4bf9..."
+func RequestTag(ctx context.Context) string {
+	tag := RequestIDFromContext(ctx)
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		tag += " trace=" + traceID
+	}
+	return tag
 }
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupFileLogger logs to a file in a fresh directory only, rotating after maxFileSize bytes
+func setupFileLogger(t *testing.T, maxFileSize int64) {
+	t.Chdir(t.TempDir())
+	SetSinks(FileSink(DEBUG))
+	SetMaxFileSize(maxFileSize)
+	if err := InitLogger(); err != nil {
+		t.Fatalf("InitLogger: %v", err)
+	}
+	t.Cleanup(func() {
+		CloseLogger()
+		SetMaxFileSize(100 << 20)
+	})
+}
+
+var workerLine = regexp.MustCompile(`worker (\d+) line (\d+)$`)
+
+// readWorkerLines counts the "worker N line M" lines in every log file
+func readWorkerLines(t *testing.T) map[string]int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(logDir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]int{}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if m := workerLine.FindStringSubmatch(scanner.Text()); m != nil {
+				seen[m[1]+"/"+m[2]]++
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return seen
+}
+
+func TestRotateLogFileWhileLogging(t *testing.T) {
+	setupFileLogger(t, 4<<10)
+	date := time.Now().Format("2006-01-02")
+
+	// Every writer also rotates now and then, so rotations overlap with each other and
+	// with writes however the goroutines are scheduled
+	const workers, lines = 16, 500
+	var rotations atomic.Int32
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				LogInfof("worker %d line %d", w, i)
+				if i%50 != 49 {
+					continue
+				}
+				ok, err := RotateLogFile()
+				if err != nil {
+					t.Errorf("RotateLogFile: %v", err)
+					return
+				}
+				if ok {
+					rotations.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if rotations.Load() == 0 {
+		t.Fatal("expected the log file to be rotated at least once")
+	}
+	if want := LogFileName(date, int(rotations.Load())); CurrentLogFile() != want {
+		t.Errorf("current log file is %s, want %s after %d rotations", CurrentLogFile(), want, rotations.Load())
+	}
+
+	seen := readWorkerLines(t)
+	for w := 0; w < workers; w++ {
+		for i := 0; i < lines; i++ {
+			key := strconv.Itoa(w) + "/" + strconv.Itoa(i)
+			if seen[key] != 1 {
+				t.Errorf("line %d of worker %d written %d times, want 1", i, w, seen[key])
+			}
+		}
+	}
+}
+
+func TestRotateLogFileKeepsSmallFile(t *testing.T) {
+	setupFileLogger(t, 1<<20)
+
+	before := CurrentLogFile()
+	LogInfo("a short line")
+	ok, err := RotateLogFile()
+	if err != nil {
+		t.Fatalf("RotateLogFile: %v", err)
+	}
+	if ok || CurrentLogFile() != before {
+		t.Errorf("rotated to %s although %s is below the maximum size", CurrentLogFile(), before)
+	}
+}
+
+func TestLoggingAfterClose(t *testing.T) {
+	setupFileLogger(t, 0)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				LogInfo(fmt.Sprintf("worker %d line %d", w, i))
+			}
+		}()
+	}
+	CloseLogger()
+	wg.Wait()
+
+	if name := CurrentLogFile(); name != "" {
+		t.Errorf("current log file is %s after close, want none", name)
+	}
+}
@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Sink is a destination for log lines. Sinks must be safe for concurrent use.
+type Sink interface {
+	// Write writes a formatted log line ending in a newline. line is only valid for
+	// the duration of the call.
+	Write(level LogLevel, line []byte) error
+	// MinLevel is the lowest level written to the sink
+	MinLevel() LogLevel
+	// Close flushes pending lines and releases the sink
+	Close() error
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   []Sink
+)
+
+// SetSinks replaces the sinks log lines are written to. Sinks that are not passed again
+// are not closed.
+func SetSinks(s ...Sink) {
+	sinksMu.Lock()
+	sinks = s
+	sinksMu.Unlock()
+}
+
+// closeSinks closes every sink, reporting failures on stderr since the sinks themselves
+// may be what failed
+func closeSinks() {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: failed to close %T: %v\n", s, err)
+		}
+	}
+}
+
+// levelWriter is the writer of the logger of a level; it passes each line to the sinks
+// accepting the level
+type levelWriter LogLevel
+
+func (lw levelWriter) Write(p []byte) (int, error) {
+	level := LogLevel(lw)
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	for _, s := range sinks {
+		if level < s.MinLevel() {
+			continue
+		}
+		if err := s.Write(level, p); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: failed to write to %T: %v\n", s, err)
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the name of the level as used in log line prefixes
+func (l LogLevel) String() string {
+	switch l {
+	case DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARNING:
+		return "WARNING"
+	case ERROR:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLevel parses a level name such as "info" or "WARNING"
+func ParseLevel(s string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return DEBUG, nil
+	case "INFO":
+		return INFO, nil
+	case "WARNING", "WARN":
+		return WARNING, nil
+	case "ERROR":
+		return ERROR, nil
+	}
+	return DEBUG, fmt.Errorf("unknown log level %q", s)
+}
+
+// ConsoleSink writes errors to stderr and everything else to stdout
+type ConsoleSink struct {
+	mu     sync.Mutex
+	out    io.Writer
+	err    io.Writer
+	min    LogLevel
+	colors bool
+}
+
+// NewConsoleSink creates a console sink; colors highlights lines by level with ANSI codes
+func NewConsoleSink(min LogLevel, colors bool) *ConsoleSink {
+	return &ConsoleSink{out: os.Stdout, err: os.Stderr, min: min, colors: colors}
+}
+
+// levelColors are the ANSI colors of the levels on the console
+var levelColors = map[LogLevel]string{
+	DEBUG:   "\033[90m",
+	INFO:    "\033[32m",
+	WARNING: "\033[33m",
+	ERROR:   "\033[31m",
+}
+
+func (cs *ConsoleSink) Write(level LogLevel, line []byte) error {
+	w := cs.out
+	if level >= ERROR {
+		w = cs.err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if !cs.colors {
+		_, err := w.Write(line)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s%s\033[0m\n", levelColors[level], bytes.TrimRight(line, "\n"))
+	return err
+}
+
+func (cs *ConsoleSink) MinLevel() LogLevel { return cs.min }
+
+func (cs *ConsoleSink) Close() error { return nil }
+
+// fileSink writes to the rotating log file managed by this package
+type fileSink struct {
+	min LogLevel
+}
+
+// FileSink returns a sink writing to the rotating log file opened by InitLogger and
+// rotated by RotateLogFile. Closing it closes the log file.
+func FileSink(min LogLevel) Sink {
+	return fileSink{min: min}
+}
+
+func (fs fileSink) Write(level LogLevel, line []byte) error {
+	_, err := output.Write(line)
+	return err
+}
+
+func (fs fileSink) MinLevel() LogLevel { return fs.min }
+
+func (fs fileSink) Close() error {
+	output.close()
+	return nil
+}
@@ -0,0 +1,281 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPSinkConfig configures shipping logs to a remote collector
+type HTTPSinkConfig struct {
+	URL           string
+	MinLevel      LogLevel
+	Service       string        // reported with every record
+	BatchSize     int           // records per request, 100 by default
+	FlushInterval time.Duration // maximum delay before a partial batch is sent, 5s by default
+	QueueSize     int           // records held in memory, 10000 by default
+	BufferDir     string        // where undeliverable records are kept; empty drops them
+	MaxBufferSize int64         // size cap of the on-disk buffer, 100 MB by default
+	Client        *http.Client
+}
+
+// LogRecord is a log line as sent to the collector
+type LogRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Severity  string    `json:"severity"`
+	Body      string    `json:"body"`
+	Service   string    `json:"service,omitempty"`
+}
+
+// HTTPSink ships log records in JSON batches ({"records": [...]}) to a collector. Records
+// are queued in memory; when the queue is full or the collector is unavailable they are
+// appended to an on-disk buffer which is replayed once the collector accepts requests again.
+type HTTPSink struct {
+	cfg        HTTPSinkConfig
+	queue      chan LogRecord
+	bufferPath string
+	bufferMu   sync.Mutex
+	dropped    atomic.Int64
+	closed     atomic.Bool
+	done       chan struct{}
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewHTTPSink creates an HTTP sink and starts shipping in the background
+func NewHTTPSink(cfg HTTPSinkConfig) (*HTTPSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("log collector URL is required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.MaxBufferSize <= 0 {
+		cfg.MaxBufferSize = 100 << 20
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	hs := &HTTPSink{
+		cfg:   cfg,
+		queue: make(chan LogRecord, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	if cfg.BufferDir != "" {
+		if err := os.MkdirAll(cfg.BufferDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log buffer directory: %v", err)
+		}
+		hs.bufferPath = filepath.Join(cfg.BufferDir, "pending.ndjson")
+	}
+
+	hs.wg.Add(1)
+	go hs.run()
+	return hs, nil
+}
+
+// Write queues a line without blocking. When the queue is full the record goes to the
+// on-disk buffer, or is dropped if there is none.
+func (hs *HTTPSink) Write(level LogLevel, line []byte) error {
+	record := LogRecord{
+		Timestamp: time.Now(),
+		Severity:  level.String(),
+		Body:      string(bytes.TrimRight(line, "\n")),
+		Service:   hs.cfg.Service,
+	}
+	if hs.closed.Load() {
+		hs.dropped.Add(1)
+		return nil
+	}
+	select {
+	case hs.queue <- record:
+		return nil
+	default:
+	}
+	hs.spill([]LogRecord{record})
+	return nil
+}
+
+func (hs *HTTPSink) MinLevel() LogLevel { return hs.cfg.MinLevel }
+
+// Dropped returns the number of records that could neither be shipped nor buffered
+func (hs *HTTPSink) Dropped() int64 {
+	return hs.dropped.Load()
+}
+
+// Close sends the queued records, buffering them on disk if the collector is unavailable.
+// It is safe to call more than once.
+func (hs *HTTPSink) Close() error {
+	hs.closeOnce.Do(func() {
+		hs.closed.Store(true)
+		close(hs.done)
+		hs.wg.Wait()
+	})
+	if n := hs.dropped.Load(); n > 0 {
+		return fmt.Errorf("%d log records were dropped", n)
+	}
+	return nil
+}
+
+// run batches queued records and ships them until the sink is closed
+func (hs *HTTPSink) run() {
+	defer hs.wg.Done()
+	ticker := time.NewTicker(hs.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]LogRecord, 0, hs.cfg.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			hs.ship(batch)
+			batch = make([]LogRecord, 0, hs.cfg.BatchSize)
+		}
+	}
+	for {
+		select {
+		case record := <-hs.queue:
+			batch = append(batch, record)
+			if len(batch) >= hs.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			hs.replay()
+		case <-hs.done:
+			for {
+				select {
+				case record := <-hs.queue:
+					batch = append(batch, record)
+					if len(batch) >= hs.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// ship sends a batch, buffering it on disk when the collector is unavailable
+func (hs *HTTPSink) ship(batch []LogRecord) {
+	if err := hs.send(batch); err != nil {
+		hs.spill(batch)
+	}
+}
+
+// send posts a batch to the collector. Rejected batches (4xx other than 429) are dropped
+// since retrying them cannot succeed.
+func (hs *HTTPSink) send(batch []LogRecord) error {
+	body, err := json.Marshal(map[string]interface{}{"records": batch})
+	if err != nil {
+		hs.dropped.Add(int64(len(batch)))
+		return nil
+	}
+	resp, err := hs.cfg.Client.Post(hs.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("log collector returned %s", resp.Status)
+	}
+	fmt.Fprintf(os.Stderr, "logger: log collector rejected %d records: %s\n", len(batch), resp.Status)
+	hs.dropped.Add(int64(len(batch)))
+	return nil
+}
+
+// spill appends records to the on-disk buffer, dropping them when there is no buffer or
+// it is full
+func (hs *HTTPSink) spill(records []LogRecord) {
+	if hs.bufferPath == "" {
+		hs.dropped.Add(int64(len(records)))
+		return
+	}
+	hs.bufferMu.Lock()
+	defer hs.bufferMu.Unlock()
+
+	if info, err := os.Stat(hs.bufferPath); err == nil && info.Size() >= hs.cfg.MaxBufferSize {
+		hs.dropped.Add(int64(len(records)))
+		return
+	}
+	f, err := os.OpenFile(hs.bufferPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		hs.dropped.Add(int64(len(records)))
+		return
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for i, record := range records {
+		if err := enc.Encode(record); err != nil {
+			hs.dropped.Add(int64(len(records) - i))
+			return
+		}
+	}
+}
+
+// replay sends the records buffered on disk in batches. Records that still cannot be
+// delivered are buffered again.
+func (hs *HTTPSink) replay() {
+	if hs.bufferPath == "" {
+		return
+	}
+	hs.bufferMu.Lock()
+	replayPath := hs.bufferPath + ".replay"
+	if _, err := os.Stat(replayPath); os.IsNotExist(err) {
+		if err := os.Rename(hs.bufferPath, replayPath); err != nil {
+			hs.bufferMu.Unlock()
+			return
+		}
+	}
+	hs.bufferMu.Unlock()
+
+	f, err := os.Open(replayPath)
+	if err != nil {
+		return
+	}
+	defer os.Remove(replayPath)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	batch := make([]LogRecord, 0, hs.cfg.BatchSize)
+	failed := false
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if failed || hs.send(batch) != nil {
+			failed = true
+			hs.spill(batch)
+		}
+		batch = batch[:0]
+	}
+	for scanner.Scan() {
+		var record LogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		batch = append(batch, record)
+		if len(batch) >= hs.cfg.BatchSize {
+			flush()
+		}
+	}
+	flush()
+}
@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"bytes"
+	"log/syslog"
+)
+
+// SyslogSink writes to a local or remote syslog daemon with the matching severity
+type SyslogSink struct {
+	w   *syslog.Writer
+	min LogLevel
+}
+
+// NewSyslogSink connects to syslog. An empty network and address use the local daemon;
+// otherwise network is "udp" or "tcp" and address is "host:port".
+func NewSyslogSink(min LogLevel, network, address, tag string) (*SyslogSink, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w, min: min}, nil
+}
+
+func (ss *SyslogSink) Write(level LogLevel, line []byte) error {
+	msg := string(bytes.TrimRight(line, "\n"))
+	switch level {
+	case DEBUG:
+		return ss.w.Debug(msg)
+	case WARNING:
+		return ss.w.Warning(msg)
+	case ERROR:
+		return ss.w.Err(msg)
+	}
+	return ss.w.Info(msg)
+}
+
+func (ss *SyslogSink) MinLevel() LogLevel { return ss.min }
+
+func (ss *SyslogSink) Close() error { return ss.w.Close() }
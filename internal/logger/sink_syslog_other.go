@@ -0,0 +1,19 @@
+//go:build windows || plan9
+
+package logger
+
+import "errors"
+
+// SyslogSink is not supported on Windows and Plan 9
+type SyslogSink struct{}
+
+// NewSyslogSink always fails on platforms without syslog
+func NewSyslogSink(min LogLevel, network, address, tag string) (*SyslogSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (ss *SyslogSink) Write(level LogLevel, line []byte) error { return nil }
+
+func (ss *SyslogSink) MinLevel() LogLevel { return ERROR }
+
+func (ss *SyslogSink) Close() error { return nil }
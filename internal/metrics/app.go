@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+)
+
+// Metrics recorded by the server
+var (
+	HTTPRequests = NewCounterVec("http_requests_total",
+		"Number of HTTP requests by method, route template and status.",
+		"method", "route", "status")
+	HTTPRequestDuration = NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests by method, route template and status.",
+		DefaultBuckets, "method", "route", "status")
+	FirestoreOperations = NewCounterVec("firestore_operations_total",
+		"Number of Firestore operations by operation, collection and result.",
+		"operation", "collection", "result")
+	FirestoreOperationDuration = NewHistogramVec("firestore_operation_duration_seconds",
+		"Latency of Firestore operations by operation and collection.",
+		DefaultBuckets, "operation", "collection")
+	AuthVerifications = NewCounterVec("auth_token_verifications_total",
+		"Number of Firebase ID token verifications by result.",
+		"result")
+	RateLimited = NewCounterVec("rate_limited_requests_total",
+		"Number of requests rejected by rate limits by route template and scope (ip, uid or auth_failures).",
+		"route", "scope")
+	DeprecatedRequests = NewCounterVec("deprecated_requests_total",
+		"Number of requests to deprecated routes by method and route template.",
+		"method", "route")
+	ActiveStreams = NewGaugeVec("active_streams",
+		"Number of open streaming (Server-Sent Events) connections by stream.",
+		"stream")
+)
+
+// ObserveFirestoreOperation records the outcome and latency of a Firestore operation
+func ObserveFirestoreOperation(operation, collection string, success bool, duration time.Duration) {
+	collection = collectionTemplate(collection)
+	result := "success"
+	if !success {
+		result = "error"
+	}
+	FirestoreOperations.Inc(operation, collection, result)
+	FirestoreOperationDuration.Observe(duration.Seconds(), operation, collection)
+}
+
+// collectionTemplate replaces the document IDs in a collection path such as
+// chats/{roomId}/messages, so that subcollections form a single series
+func collectionTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i += 2 {
+		segments[i] = "{id}"
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,263 @@
+// Package metrics keeps counters, gauges and histograms in memory and exposes them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the latency buckets in seconds used for request and operation durations
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector writes one metric family in the text format
+type collector interface {
+	writeTo(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+}
+
+// WriteText writes every registered metric and the Go runtime metrics to w
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+	for _, c := range collectors {
+		c.writeTo(w)
+	}
+	writeRuntimeMetrics(w)
+}
+
+// Handler serves the metrics for Prometheus to scrape
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+// vec holds the series of a metric family keyed by their label values
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+	create func() *T
+}
+
+func newVec[T any](name, help string, labels []string, create func() *T) *vec[T] {
+	return &vec[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		series: map[string]*T{},
+		values: map[string][]string{},
+		create: create,
+	}
+}
+
+// with returns the series for the label values, creating it on first use. The caller
+// must hold v.mu.
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = v.create()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// sortedKeys returns the series keys in a stable order. The caller must hold v.mu.
+func (v *vec[T]) sortedKeys() []string {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec[T]) writeHeader(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, kind)
+}
+
+// CounterVec is a family of counters partitioned by labels
+type CounterVec struct {
+	*vec[float64]
+}
+
+// NewCounterVec creates and registers a counter family
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, labels, func() *float64 { return new(float64) })}
+	register(c)
+	return c
+}
+
+// Inc increments the counter with the given label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the counter with the given label values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	*c.with(values) += delta
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w, "counter")
+	for _, k := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.values[k]), formatValue(*c.series[k]))
+	}
+}
+
+// GaugeVec is a family of gauges partitioned by labels
+type GaugeVec struct {
+	*vec[float64]
+}
+
+// NewGaugeVec creates and registers a gauge family
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, labels, func() *float64 { return new(float64) })}
+	register(g)
+	return g
+}
+
+// Add adds delta to the gauge with the given label values
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.mu.Lock()
+	*g.with(values) += delta
+	g.mu.Unlock()
+}
+
+// Set sets the gauge with the given label values
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.mu.Lock()
+	*g.with(values) = value
+	g.mu.Unlock()
+}
+
+func (g *GaugeVec) writeTo(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w, "gauge")
+	for _, k := range g.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, g.values[k]), formatValue(*g.series[k]))
+	}
+}
+
+// histogram is a single histogram series; counts[i] counts observations in bucket i only
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a family of histograms partitioned by labels
+type HistogramVec struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates and registers a histogram family with the given upper bounds
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec:     newVec(name, help, labels, func() *histogram { return &histogram{counts: make([]uint64, len(buckets))} }),
+		buckets: buckets,
+	}
+	register(h)
+	return h
+}
+
+// Observe records a value in the histogram with the given label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.with(values)
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w, "histogram")
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, k := range h.sortedKeys() {
+		s, values := h.series[k], h.values[k]
+		values = values[:len(values):len(values)] // appending "le" must not modify the series
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels, append(values, formatValue(upper))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels, append(values, "+Inf")), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, values), s.count)
+	}
+}
+
+// formatLabels formats label pairs as {name="value",...}, or "" without labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatValue formats a sample value the way Prometheus expects
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// startTime is when the process started, reported as process_start_time_seconds
+var startTime = time.Now()
+
+// writeRuntimeMetrics writes Go runtime and process metrics
+func writeRuntimeMetrics(w io.Writer) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatValue(value))
+	}
+	counter := func(name, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %s\n", name, help, name, name, formatValue(value))
+	}
+
+	fmt.Fprintf(w, "# HELP go_info Information about the Go environment.\n# TYPE go_info gauge\ngo_info%s 1\n",
+		formatLabels([]string{"version"}, []string{runtime.Version()}))
+	gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(m.Alloc))
+	counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(m.TotalAlloc))
+	gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(m.Sys))
+	gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(m.HeapInuse))
+	gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(m.HeapObjects))
+	gauge("go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.", float64(m.StackInuse))
+	counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(m.NumGC))
+	counter("go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", float64(m.PauseTotalNs)/1e9)
+	gauge("go_gomaxprocs", "Value of GOMAXPROCS.", float64(runtime.GOMAXPROCS(0)))
+	gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(startTime.Unix()))
+}
@@ -4,48 +4,203 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"pawtroli-be/internal/apperror"
 	"pawtroli-be/internal/firebase"
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/metrics"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// Custom claims set on Firebase ID tokens when a user's role or branch changes. The branch
+// is informational for the clients; the API does not scope data by branch.
+const (
+	ClaimRole   = "role"
+	ClaimBranch = "branch"
 )
 
+// TokenRole returns the role claim of the verified token. ok is false for tokens issued
+// before the claim was set, in which case the role has to be looked up.
+func TokenRole(ctx context.Context) (role string, ok bool) {
+	token, found := ctx.Value("token").(*auth.Token)
+	if !found {
+		return "", false
+	}
+	role, ok = token.Claims[ClaimRole].(string)
+	return role, ok
+}
+
+// TokenEmail returns the email claim of the verified Firebase ID token, if any
+func TokenEmail(ctx context.Context) string {
+	token, ok := ctx.Value("token").(*auth.Token)
+	if !ok {
+		return ""
+	}
+	email, _ := token.Claims["email"].(string)
+	return email
+}
+
+// accountStateTTL is how long the disabled and revocation state of an account is cached.
+// Disabling an account or revoking its tokens takes effect on other instances within it.
+const accountStateTTL = 30 * time.Second
+
+// accountState is the cached part of a user record needed to accept a token
+type accountState struct {
+	disabled   bool
+	validAfter int64 // tokens issued before this Unix time in seconds are revoked
+	fetchedAt  time.Time
+}
+
+var (
+	accountStatesMu sync.Mutex
+	accountStates   = make(map[string]accountState)
+)
+
+// ForgetAccountState drops the cached state of uid, so that a change to the account made by
+// this instance, such as disabling it or revoking its tokens, applies to the next request
+func ForgetAccountState(uid string) {
+	accountStatesMu.Lock()
+	defer accountStatesMu.Unlock()
+	delete(accountStates, uid)
+}
+
+// getAccountState returns the state of uid, looking the user up at most once per
+// accountStateTTL instead of on every request
+func getAccountState(ctx context.Context, client *auth.Client, uid string) (accountState, error) {
+	now := time.Now()
+	accountStatesMu.Lock()
+	state, ok := accountStates[uid]
+	accountStatesMu.Unlock()
+	if ok && now.Sub(state.fetchedAt) < accountStateTTL {
+		return state, nil
+	}
+
+	user, err := client.GetUser(ctx, uid)
+	if err != nil {
+		return accountState{}, err
+	}
+	state = accountState{
+		disabled:   user.Disabled,
+		validAfter: user.TokensValidAfterMillis / 1000,
+		fetchedAt:  now,
+	}
+
+	accountStatesMu.Lock()
+	defer accountStatesMu.Unlock()
+	if len(accountStates) >= 10000 {
+		for uid, cached := range accountStates {
+			if now.Sub(cached.fetchedAt) >= accountStateTTL {
+				delete(accountStates, uid)
+			}
+		}
+	}
+	accountStates[uid] = state
+	return state, nil
+}
+
+// tokenVerifier is the handler returned by VerifyToken. RateLimitMiddleware recognizes it
+// and leaves limiting to it, so that authenticated requests are limited per uid.
+type tokenVerifier struct {
+	next http.Handler
+}
+
 func VerifyToken(next http.Handler) http.Handler {
 	logger.LogInfo("VerifyToken middleware initialized")
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		logger.LogDebug("VerifyToken middleware called")
+	return tokenVerifier{next: next}
+}
 
-		authHeader := r.Header.Get("Authorization")
-		logger.LogDebugf("VerifyToken: Authorization header: %s", authHeader)
+// RequiresToken reports whether h is wrapped in VerifyToken
+func RequiresToken(h http.Handler) bool {
+	_, ok := h.(tokenVerifier)
+	return ok
+}
+
+func (tv tokenVerifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	logger.LogDebug("VerifyToken middleware called")
 
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			logger.LogWarning("VerifyToken: Missing or invalid Authorization header")
-			http.Error(w, "Missing auth token", http.StatusUnauthorized)
+	authHeader := r.Header.Get("Authorization")
+	logger.LogDebugf("VerifyToken: Authorization header: %s", authHeader)
+
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		logger.LogWarning("VerifyToken: Missing or invalid Authorization header")
+		metrics.AuthVerifications.Inc("missing")
+		if rateLimiter != nil && !rateLimiter.allow(w, r, "ip", ClientIP(r)) {
 			return
 		}
+		apperror.Write(w, r, apperror.Unauthorized("Missing auth token"))
+		return
+	}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		ctx := context.Background()
-		client, err := firebase.App.Auth(ctx)
-		if err != nil {
-			logger.LogErrorf("VerifyToken: Failed to get auth client: %v", err)
-			http.Error(w, "Failed to get auth client", http.StatusInternalServerError)
-			return
+	// Clients that keep sending invalid tokens are turned away before verification
+	if rateLimiter != nil && rateLimiter.authFailuresExceeded(w, r) {
+		return
+	}
+
+	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+	ctx := r.Context()
+	client, err := firebase.App.Auth(ctx)
+	if err != nil {
+		logger.LogErrorf("VerifyToken: Failed to get auth client: %v", err)
+		metrics.AuthVerifications.Inc("error")
+		apperror.Write(w, r, apperror.Internal("Failed to get auth client", err))
+		return
+	}
+
+	// The signature and expiry are checked locally
+	token, err := client.VerifyIDToken(ctx, tokenStr)
+	if err != nil {
+		logger.LogErrorf("VerifyToken: Invalid token: %v", err)
+		logger.LogAuthOperation("token_verification", "", false)
+		metrics.AuthVerifications.Inc("invalid")
+		if rateLimiter != nil {
+			rateLimiter.recordAuthFailure(r)
 		}
+		apperror.Write(w, r, apperror.Unauthorized("Invalid token"))
+		return
+	}
 
-		token, err := client.VerifyIDToken(ctx, tokenStr)
-		duration := time.Since(start)
-		if err != nil {
-			logger.LogErrorf("VerifyToken: Invalid token: %v", err)
-			logger.LogAuthOperation("token_verification", "", false)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+	// Tokens of disabled or deleted accounts and tokens revoked after a role change stop
+	// working within accountStateTTL instead of when they expire
+	state, err := getAccountState(ctx, client, token.UID)
+	duration := time.Since(start)
+	if auth.IsUserNotFound(err) {
+		logger.LogWarningf("VerifyToken: Account %s no longer exists", token.UID)
+		logger.LogAuthOperation("token_verification", token.UID, false)
+		metrics.AuthVerifications.Inc("not_found")
+		apperror.Write(w, r, apperror.Unauthorized("Account not found"))
+		return
+	}
+	if err != nil {
+		logger.LogErrorf("VerifyToken: Failed to look up account %s: %v", token.UID, err)
+		metrics.AuthVerifications.Inc("error")
+		apperror.Write(w, r, apperror.Unavailable("Failed to verify token", err))
+		return
+	}
+	if state.disabled || token.IssuedAt < state.validAfter {
+		message := "Account disabled"
+		metric := "disabled"
+		if !state.disabled {
+			message = "Token revoked, sign in again"
+			metric = "revoked"
 		}
+		logger.LogWarningf("VerifyToken: Rejected token of %s: %s", token.UID, message)
+		logger.LogAuthOperation("token_verification", token.UID, false)
+		metrics.AuthVerifications.Inc(metric)
+		apperror.Write(w, r, apperror.Unauthorized(message))
+		return
+	}
 
-		logger.LogInfof("VerifyToken: Authenticated UID: %s (verification took %v)", token.UID, duration)
-		logger.LogAuthOperation("token_verification", token.UID, true)
-		ctx = context.WithValue(r.Context(), "uid", token.UID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	logger.LogInfof("VerifyToken: Authenticated UID: %s (verification took %v)", token.UID, duration)
+	logger.LogAuthOperation("token_verification", token.UID, true)
+	metrics.AuthVerifications.Inc("success")
+	if rateLimiter != nil && !rateLimiter.allow(w, r, "uid", token.UID) {
+		return
+	}
+	ctx = context.WithValue(r.Context(), "uid", token.UID)
+	ctx = context.WithValue(ctx, "token", token)
+	tv.next.ServeHTTP(w, r.WithContext(ctx))
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Deadlines configures how long requests may take. Routes are keyed by method and route
+// template without the API version, e.g. "GET /admin/audit/export"; a zero duration means
+// no deadline, which is needed for streams.
+type Deadlines struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// DeadlineMiddleware bounds the context of every request by the deadline of its route.
+// Handlers pass the context to Firestore and other calls, so expired requests stop their
+// work and respond 504 through apperror.
+func DeadlineMiddleware(d Deadlines) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout, ok := d.Routes[routeKey(r)]
+			if !ok {
+				timeout = d.Default
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
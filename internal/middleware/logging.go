@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/metrics"
+
+	"github.com/gorilla/mux"
 )
 
 // ResponseWriter wrapper to capture status code
@@ -18,13 +22,18 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streams
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // LoggingMiddleware logs all HTTP requests
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
 		// Log incoming request
-		logger.LogInfof("Incoming request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		logger.LogInfof("Incoming request [%s]: %s %s from %s", logger.RequestTag(r.Context()), r.Method, r.URL.Path, r.RemoteAddr)
 
 		// Wrap the response writer to capture status code
 		wrapped := &responseWriter{
@@ -38,5 +47,20 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		// Log the completed request
 		duration := time.Since(start)
 		logger.LogHTTPRequest(r.Method, r.URL.Path, r.RemoteAddr, wrapped.statusCode, duration)
+
+		route, status := routeTemplate(r), strconv.Itoa(wrapped.statusCode)
+		metrics.HTTPRequests.Inc(r.Method, route, status)
+		metrics.HTTPRequestDuration.Observe(duration.Seconds(), r.Method, route, status)
 	})
 }
+
+// routeTemplate returns the template of the matched route (e.g. /pets/{petId}), so that
+// metrics are not partitioned by IDs in the path
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/metrics"
+	"pawtroli-be/internal/ratelimit"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimits configures the limits per route, keyed by method and route template like
+// Deadlines. Routes that are not listed get the default.
+type RateLimits struct {
+	Default ratelimit.Limit
+	Routes  map[string]ratelimit.Limit
+	// AuthFailures limits failed token verifications per client IP; once exceeded,
+	// tokens from that IP are rejected without being verified
+	AuthFailures ratelimit.Limit
+}
+
+// RateLimiter applies rate limits to requests
+type RateLimiter struct {
+	store  ratelimit.Store
+	limits RateLimits
+}
+
+var (
+	rateLimiter    *RateLimiter
+	trustedProxies []*net.IPNet
+)
+
+// NewRateLimiter creates a rate limiter keeping its buckets in store
+func NewRateLimiter(store ratelimit.Store, limits RateLimits) *RateLimiter {
+	return &RateLimiter{store: store, limits: limits}
+}
+
+// SetRateLimiter enables rate limiting in RateLimitMiddleware and VerifyToken
+func SetRateLimiter(rl *RateLimiter) {
+	rateLimiter = rl
+}
+
+// SetTrustedProxies sets the proxies whose X-Forwarded-For header is honored, as IPs or
+// CIDR ranges
+func SetTrustedProxies(proxies []string) error {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %v", p, err)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the caller's IP. X-Forwarded-For is only honored when the request
+// comes from a trusted proxy, in which case the last hop not added by a trusted proxy
+// is the client.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isTrustedProxy(ip) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		hopIP := net.ParseIP(hop)
+		if hopIP == nil {
+			break
+		}
+		if !isTrustedProxy(hopIP) || i == 0 {
+			return hop
+		}
+	}
+	return host
+}
+
+// routeLimit returns the limit of the matched route
+func (rl *RateLimiter) routeLimit(r *http.Request) ratelimit.Limit {
+	if limit, ok := rl.limits.Routes[routeKey(r)]; ok {
+		return limit
+	}
+	return rl.limits.Default
+}
+
+// allow takes a token for key from the route's bucket. If none is left it writes a 429
+// and returns false. Store failures let the request through.
+func (rl *RateLimiter) allow(w http.ResponseWriter, r *http.Request, scope, key string) bool {
+	route := routeTemplate(r)
+	// Versions of a route share a bucket, so the legacy alias cannot double the limit
+	wait, err := rl.store.Take(r.Context(), scope+":"+key+":"+routeKey(r), rl.routeLimit(r))
+	if err != nil {
+		logger.LogWarningf("Rate limit check failed, allowing request: %v", err)
+		return true
+	}
+	if wait <= 0 {
+		return true
+	}
+	logger.LogWarningf("Rate limit exceeded by %s %s on %s %s", scope, key, r.Method, route)
+	metrics.RateLimited.Inc(route, scope)
+	writeRateLimited(w, r, wait)
+	return false
+}
+
+// authFailuresExceeded writes a 429 and returns true if the client IP failed token
+// verification too often
+func (rl *RateLimiter) authFailuresExceeded(w http.ResponseWriter, r *http.Request) bool {
+	ip := ClientIP(r)
+	wait, err := rl.store.Peek(r.Context(), "authfail:"+ip, rl.limits.AuthFailures)
+	if err != nil {
+		logger.LogWarningf("Rate limit check failed, allowing request: %v", err)
+		return false
+	}
+	if wait <= 0 {
+		return false
+	}
+	logger.LogWarningf("Too many failed token verifications from %s", ip)
+	metrics.RateLimited.Inc(routeTemplate(r), "auth_failures")
+	writeRateLimited(w, r, wait)
+	return true
+}
+
+// recordAuthFailure counts a failed token verification against the client IP
+func (rl *RateLimiter) recordAuthFailure(r *http.Request) {
+	if _, err := rl.store.Take(r.Context(), "authfail:"+ClientIP(r), rl.limits.AuthFailures); err != nil {
+		logger.LogWarningf("Failed to record auth failure: %v", err)
+	}
+}
+
+func writeRateLimited(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	apperror.Write(w, r, apperror.RateLimited("Too many requests, please retry later"))
+}
+
+// requiresToken reports whether the matched route is wrapped in VerifyToken
+func requiresToken(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	return RequiresToken(route.GetHandler())
+}
+
+// RateLimitMiddleware limits requests per client IP. Routes wrapped in VerifyToken are
+// limited per uid by VerifyToken instead, once the token is verified.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimiter != nil && !requiresToken(r) {
+			if !rateLimiter.allow(w, r, "ip", ClientIP(r)) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pawtroli-be/internal/ratelimit"
+)
+
+// fixedStore answers every Take and Peek with the same wait
+type fixedStore struct {
+	wait time.Duration
+	err  error
+}
+
+func (s fixedStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (time.Duration, error) {
+	return s.wait, s.err
+}
+
+func (s fixedStore) Peek(ctx context.Context, key string, limit ratelimit.Limit) (time.Duration, error) {
+	return s.wait, s.err
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      fixedStore
+		wantStatus int
+		retryAfter string
+	}{
+		{"token available", fixedStore{}, http.StatusOK, ""},
+		{"whole seconds", fixedStore{wait: 3 * time.Second}, http.StatusTooManyRequests, "3"},
+		{"rounds up partial seconds", fixedStore{wait: 1500 * time.Millisecond}, http.StatusTooManyRequests, "2"},
+		{"never advertises zero", fixedStore{wait: 10 * time.Millisecond}, http.StatusTooManyRequests, "1"},
+		{"store failure lets the request through", fixedStore{wait: time.Second, err: errors.New("down")}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter(tt.store, RateLimits{Default: ratelimit.PerMinute(60, 1), AuthFailures: ratelimit.PerMinute(10, 10)})
+			for _, check := range []struct {
+				name string
+				run  func(w http.ResponseWriter, r *http.Request) bool
+			}{
+				{"allow", func(w http.ResponseWriter, r *http.Request) bool { return rl.allow(w, r, "ip", "192.0.2.1") }},
+				{"auth failures", func(w http.ResponseWriter, r *http.Request) bool { return !rl.authFailuresExceeded(w, r) }},
+			} {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/pets", nil)
+				if check.run(w, r) {
+					w.WriteHeader(http.StatusOK)
+				}
+				if w.Code != tt.wantStatus {
+					t.Errorf("%s: status = %d, want %d", check.name, w.Code, tt.wantStatus)
+				}
+				if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+					t.Errorf("%s: Retry-After = %q, want %q", check.name, got, tt.retryAfter)
+				}
+			}
+		})
+	}
+}
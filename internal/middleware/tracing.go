@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware creates a server span for each request, continuing the caller's trace
+// when a traceparent header is present. Spans are named after the matched route template.
+func TracingMiddleware(next http.Handler) http.Handler {
+	withRoute := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.route", routeTemplate(r)))
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(withRoute, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + routeTemplate(r)
+		}),
+	)
+}
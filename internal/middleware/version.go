@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"pawtroli-be/internal/metrics"
+)
+
+// versionSegment matches the API version at the start of a path, e.g. /v1
+var versionSegment = regexp.MustCompile(`^/v[0-9]+(/|$)`)
+
+// UnversionedTemplate strips the API version from a route template, so that /v1/pets and
+// its legacy alias /pets share the configuration keyed by "GET /pets"
+func UnversionedTemplate(template string) string {
+	return stripVersion(template)
+}
+
+// UnversionedPath strips the API version from a request path, e.g. /v1/pets/abc to /pets/abc
+func UnversionedPath(path string) string {
+	return stripVersion(path)
+}
+
+func stripVersion(path string) string {
+	if loc := versionSegment.FindStringIndex(path); loc != nil {
+		return "/" + path[loc[1]:]
+	}
+	return path
+}
+
+// routeKey identifies the matched route in route-keyed configuration such as Deadlines
+// and RateLimits, e.g. "GET /pets/{petId}"
+func routeKey(r *http.Request) string {
+	return r.Method + " " + UnversionedTemplate(routeTemplate(r))
+}
+
+// Deprecation describes routes that are being phased out
+type Deprecation struct {
+	Since  time.Time // when the routes were deprecated
+	Sunset time.Time // when they stop working; not announced when zero
+	// Successor returns the path replacing the requested one, announced in a Link header
+	Successor func(r *http.Request) string
+}
+
+// DeprecationMiddleware announces the deprecation of the routes it wraps with the
+// Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers, and counts their use so
+// that the remaining clients can be tracked before the sunset.
+func DeprecationMiddleware(d Deprecation) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(d.Since.Unix(), 10)
+	sunset := ""
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", deprecation)
+			if sunset != "" {
+				h.Set("Sunset", sunset)
+			}
+			if d.Successor != nil {
+				h.Add("Link", "<"+d.Successor(r)+`>; rel="successor-version"`)
+			}
+			metrics.DeprecatedRequests.Inc(r.Method, routeTemplate(r))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
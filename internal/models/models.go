@@ -7,35 +7,41 @@ type User struct {
 	Name      string    `firestore:"name"`
 	Email     string    `firestore:"email"`
 	Phone     string    `firestore:"phone"`
-	Role      string    `firestore:"role"` // "user" or "admin"
+	Role      string    `firestore:"role"`     // "user" or "admin", mirrored in the token claims
+	Branch    string    `firestore:"branch"`   // hotel branch of staff members, mirrored in the token claims
+	Language  string    `firestore:"language"` // "id" or "en", used for emails
+	PhotoURL  string    `firestore:"photoUrl"`
+	Disabled  bool      `firestore:"disabled"` // mirrors the Firebase Auth account state
 	CreatedAt time.Time `firestore:"createdAt"`
 }
 
 type Pet struct {
-	PetID     string    `json:"petId" firestore:"petId"` // <-- store PetID in a petId field
-	Name      string    `json:"name" firestore:"name"`
-	Type      string    `json:"type" firestore:"type"`
-	Gender    string    `json:"gender" firestore:"gender"`
-	Age       int       `json:"age" firestore:"age"`
-	Color     string    `json:"color" firestore:"color"`
-	Allergy   string    `json:"allergy" firestore:"allergy"`
-	Other     string    `json:"other" firestore:"other"`
-	OwnerID   string    `json:"ownerId" firestore:"ownerId"`
-	ImageURL  string    `json:"imageUrl" firestore:"imageUrl"`
-	Active    bool      `json:"active" firestore:"active"`
-	Status    string    `json:"status" firestore:"status"`
-	CheckIn   time.Time `json:"checkIn" firestore:"checkIn"`
-	CheckOut  time.Time `json:"checkOut" firestore:"checkOut"`
-	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
+	PetID     string    `firestore:"petId"` // <-- store PetID in a petId field
+	Name      string    `firestore:"name"`
+	Type      string    `firestore:"type"`
+	Gender    string    `firestore:"gender"`
+	Age       int       `firestore:"age"`
+	Color     string    `firestore:"color"`
+	Allergy   string    `firestore:"allergy"`
+	Other     string    `firestore:"other"`
+	OwnerID   string    `firestore:"ownerId"`
+	ImageURL  string    `firestore:"imageUrl"`
+	Active    bool      `firestore:"active"`
+	Status    string    `firestore:"status"`
+	CheckIn   time.Time `firestore:"checkIn"`
+	CheckOut  time.Time `firestore:"checkOut"`
+	CreatedAt time.Time `firestore:"createdAt"`
+	Deleted   bool      `firestore:"deleted"` // soft deleted, hidden together with its updates
+	DeletedAt time.Time `firestore:"deletedAt"`
 }
 
 type PetUpdate struct {
-	ID          string    `json:"id" firestore:"-"`
-	Caption     string    `json:"caption" firestore:"caption"`
-	Description string    `json:"description" firestore:"description"`
-	ImageURL    string    `json:"imageUrl" firestore:"imageUrl"`
-	PetID       string    `json:"petId" firestore:"petId"` // already stored
-	Timestamp   time.Time `json:"timestamp" firestore:"timestamp"`
+	ID          string    `firestore:"-"`
+	Caption     string    `firestore:"caption"`
+	Description string    `firestore:"description"`
+	ImageURL    string    `firestore:"imageUrl"`
+	PetID       string    `firestore:"petId"` // already stored
+	Timestamp   time.Time `firestore:"timestamp"`
 }
 
 type ChatRoom struct {
@@ -51,3 +57,72 @@ type Message struct {
 	Content   string    `firestore:"content"`
 	Timestamp time.Time `firestore:"timestamp"`
 }
+
+type DeviceToken struct {
+	Token     string    `firestore:"token"`    // FCM registration token, also the document ID
+	Platform  string    `firestore:"platform"` // "android", "ios" or "web"
+	CreatedAt time.Time `firestore:"createdAt"`
+}
+
+type NotificationPreferences struct {
+	PetUpdates    bool `firestore:"petUpdates"`
+	ChatMessages  bool `firestore:"chatMessages"`
+	PetActivation bool `firestore:"petActivation"`
+}
+
+type Job struct {
+	ID          string    `firestore:"-"` // use for document ID
+	Type        string    `firestore:"type"`
+	Payload     string    `firestore:"payload"` // JSON encoded job arguments
+	Status      string    `firestore:"status"`  // "pending", "running", "succeeded" or "dead"
+	Attempts    int       `firestore:"attempts"`
+	MaxAttempts int       `firestore:"maxAttempts"`
+	RunAt       time.Time `firestore:"runAt"`
+	LeaseUntil  time.Time `firestore:"leaseUntil"`
+	LastError   string    `firestore:"lastError"`
+	CreatedAt   time.Time `firestore:"createdAt"`
+	UpdatedAt   time.Time `firestore:"updatedAt"`
+}
+
+type DeletionTask struct {
+	ID          string         `firestore:"-"`    // use for document ID
+	Kind        string         `firestore:"kind"` // "pet" or "user"
+	TargetID    string         `firestore:"targetId"`
+	Status      string         `firestore:"status"`  // "pending", "running", "done" or "failed"
+	Deleted     map[string]int `firestore:"deleted"` // deleted documents and media per collection
+	Anonymized  map[string]int `firestore:"anonymized"`
+	LastError   string         `firestore:"lastError"`
+	CreatedAt   time.Time      `firestore:"createdAt"`
+	UpdatedAt   time.Time      `firestore:"updatedAt"`
+	CompletedAt time.Time      `firestore:"completedAt"`
+}
+
+type AuditEntry struct {
+	ID           string                 `firestore:"-"` // use for document ID
+	ActorUID     string                 `firestore:"actorUid"`
+	ActorRole    string                 `firestore:"actorRole"`
+	Action       string                 `firestore:"action"` // e.g. "user.role_changed"
+	ResourceType string                 `firestore:"resourceType"`
+	ResourceID   string                 `firestore:"resourceId"`
+	Before       map[string]interface{} `firestore:"before"`
+	After        map[string]interface{} `firestore:"after"`
+	RequestID    string                 `firestore:"requestId"`
+	IP           string                 `firestore:"ip"`
+	Timestamp    time.Time              `firestore:"timestamp"`
+}
+
+type IdempotencyRecord struct {
+	ID          string    `firestore:"-"`     // use for document ID, derived from scope and key
+	Scope       string    `firestore:"scope"` // uid of the caller
+	Key         string    `firestore:"key"`
+	RequestHash string    `firestore:"requestHash"` // SHA-256 of method, unversioned path and body
+	Status      string    `firestore:"status"`      // "processing" or "completed"
+	StatusCode  int       `firestore:"statusCode"`
+	ContentType string    `firestore:"contentType"`
+	Location    string    `firestore:"location"`
+	Body        []byte    `firestore:"body"`
+	ClaimToken  string    `firestore:"claimToken"`  // identifies the request holding a processing record
+	LockedUntil time.Time `firestore:"lockedUntil"` // a processing record older than this was abandoned
+	CreatedAt   time.Time `firestore:"createdAt"`
+	ExpiresAt   time.Time `firestore:"expiresAt"`
+}
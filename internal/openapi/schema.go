@@ -0,0 +1,178 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"pawtroli-be/internal/validation"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+	optionalType = reflect.TypeOf((*validation.Optional)(nil)).Elem()
+)
+
+// SchemaOf returns the schema of the Go value v. Named struct types are added to the
+// components and referenced. Constraints are taken from the `validate` tags understood by
+// the validation package.
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{Description: "Any JSON value"}
+	case t.Implements(optionalType):
+		// Merge-patch fields take the schema of their value and may be null to clear it
+		field, _ := t.FieldByName("Value")
+		s := d.schemaOf(field.Type)
+		s.Nullable = true
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			d.Components.Schemas[name] = &Schema{} // placeholder for recursive types
+			d.Components.Schemas[name] = d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// structSchema returns the object schema of a struct type, inlining embedded structs the
+// way encoding/json does
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			d.addFields(s, f.Type)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := d.schemaOf(f.Type)
+		required := applyRules(prop, f.Tag.Get("validate"))
+		if f.Type.Implements(optionalType) {
+			// Merge-patch fields are never required; required ones cannot be cleared
+			if required {
+				prop.Nullable = false
+				if prop.Type == "string" {
+					prop.MinLength = intPtr(1)
+				}
+			}
+		} else if required {
+			s.Required = append(s.Required, name)
+		} else if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && f.Tag.Get("validate") == "" {
+			// Response fields are always present unless omitted when empty
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyRules adds the constraints of a validate tag to s and reports whether the field is
+// required. Rules after "dive" apply to the items of an array.
+func applyRules(s *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	required := false
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if target == s {
+				required = true
+			} else if target.Type == "string" {
+				target.MinLength = intPtr(1)
+			}
+		case "dive":
+			if target.Items != nil {
+				target = target.Items
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch {
+			case target.Type == "string" && name == "min":
+				target.MinLength = intPtr(n)
+			case target.Type == "string":
+				target.MaxLength = intPtr(n)
+			case target.Type == "array" && name == "min":
+				target.MinItems = intPtr(n)
+			case target.Type == "array":
+				target.MaxItems = intPtr(n)
+			case name == "min":
+				f := float64(n)
+				target.Minimum = &f
+			default:
+				f := float64(n)
+				target.Maximum = &f
+			}
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "rfc3339":
+			target.Format = "date-time"
+		case "e164":
+			target.Pattern = `^\+[1-9][0-9]{6,14}$`
+		case "docid":
+			target.Pattern = `^[^/]+$`
+		}
+	}
+	return required
+}
+
+func intPtr(n int) *int { return &n }
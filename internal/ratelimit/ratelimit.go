@@ -0,0 +1,122 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit is a token bucket: Rate tokens are added per second up to Burst
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+// PerMinute returns a limit of n requests per minute with bursts of up to burst requests
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: rate.Limit(float64(n) / 60), Burst: burst}
+}
+
+// Unlimited reports whether the limit allows every request
+func (l Limit) Unlimited() bool {
+	return l.Rate == rate.Inf || l.Burst <= 0
+}
+
+// Store keeps the token buckets. The in-memory store limits each instance separately;
+// a shared store (e.g. Redis) makes the limits apply across instances.
+type Store interface {
+	// Take takes a token from the bucket of key. It returns zero if the request may
+	// proceed, or how long to wait until a token is available.
+	Take(ctx context.Context, key string, limit Limit) (time.Duration, error)
+	// Peek returns how long to wait until the bucket of key has a token, without taking it
+	Peek(ctx context.Context, key string, limit Limit) (time.Duration, error)
+}
+
+// memoryBucket is a bucket of the in-memory store
+type memoryBucket struct {
+	limiter  *rate.Limiter
+	limit    Limit
+	lastSeen time.Time
+}
+
+// MemoryStore keeps buckets in memory, removing buckets that were idle for a while. The
+// idle time should be longer than buckets take to refill.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an in-memory store that forgets buckets idle for longer than idle
+func NewMemoryStore(idle time.Duration) *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*memoryBucket{},
+		idle:      idle,
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take takes a token from the bucket of key
+func (ms *MemoryStore) Take(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	if limit.Unlimited() {
+		return 0, nil
+	}
+	now := ms.now()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	b := ms.bucket(key, limit, now)
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, nil
+	}
+	return 0, nil
+}
+
+// Peek returns how long to wait until the bucket of key has a token
+func (ms *MemoryStore) Peek(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	if limit.Unlimited() {
+		return 0, nil
+	}
+	now := ms.now()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	b := ms.bucket(key, limit, now)
+	missing := 1 - b.limiter.TokensAt(now)
+	if missing <= 0 {
+		return 0, nil
+	}
+	return time.Duration(missing / float64(limit.Rate) * float64(time.Second)), nil
+}
+
+// bucket returns the bucket of key, creating it if needed. The caller must hold ms.mu.
+func (ms *MemoryStore) bucket(key string, limit Limit, now time.Time) *memoryBucket {
+	if now.Sub(ms.lastSweep) > ms.idle {
+		ms.sweep(now)
+	}
+	b, ok := ms.buckets[key]
+	if !ok || b.limit != limit {
+		b = &memoryBucket{limiter: rate.NewLimiter(limit.Rate, limit.Burst), limit: limit}
+		ms.buckets[key] = b
+	}
+	b.lastSeen = now
+	return b
+}
+
+// sweep removes idle buckets. The caller must hold ms.mu.
+func (ms *MemoryStore) sweep(now time.Time) {
+	for key, b := range ms.buckets {
+		if now.Sub(b.lastSeen) > ms.idle {
+			delete(ms.buckets, key)
+		}
+	}
+	ms.lastSweep = now
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a store whose clock only moves when the returned function is called
+func newTestStore(idle time.Duration) (*MemoryStore, func(time.Duration)) {
+	ms := NewMemoryStore(idle)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms.lastSweep = now
+	ms.now = func() time.Time { return now }
+	return ms, func(d time.Duration) { now = now.Add(d) }
+}
+
+// closeTo reports whether got is within a millisecond of want, absorbing float rounding
+func closeTo(got, want time.Duration) bool {
+	d := got - want
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func TestMemoryStoreRefillsBuckets(t *testing.T) {
+	limit := PerMinute(60, 3) // one token per second, three at once
+	steps := []struct {
+		name    string
+		advance time.Duration
+		peek    bool
+		want    time.Duration
+	}{
+		{"first of the burst", 0, false, 0},
+		{"second of the burst", 0, false, 0},
+		{"last of the burst", 0, false, 0},
+		{"empty bucket", 0, false, time.Second},
+		{"rejected requests take no token", 0, false, time.Second},
+		{"half refilled", 500 * time.Millisecond, false, 500 * time.Millisecond},
+		{"peek while refilling", 0, true, 500 * time.Millisecond},
+		{"one token refilled", 500 * time.Millisecond, true, 0},
+		{"peek takes no token", 0, false, 0},
+		{"empty again", 0, false, time.Second},
+		{"refills up to the burst", time.Minute, false, 0},
+		{"second after refill", 0, false, 0},
+		{"third after refill", 0, false, 0},
+		{"no more than the burst", 0, false, time.Second},
+	}
+
+	ms, advance := newTestStore(time.Hour)
+	ctx := context.Background()
+	for _, step := range steps {
+		advance(step.advance)
+		take := ms.Take
+		if step.peek {
+			take = ms.Peek
+		}
+		got, err := take(ctx, "client", limit)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !closeTo(got, step.want) {
+			t.Errorf("%s: wait = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestMemoryStoreSeparatesBuckets(t *testing.T) {
+	ms, _ := newTestStore(time.Hour)
+	ctx := context.Background()
+	limit := PerMinute(60, 1)
+
+	if wait, _ := ms.Take(ctx, "a", limit); wait != 0 {
+		t.Fatalf("first request of a waits %v", wait)
+	}
+	tests := []struct {
+		name  string
+		key   string
+		limit Limit
+		want  time.Duration
+	}{
+		{"same key is limited", "a", limit, time.Second},
+		{"other keys have their own bucket", "b", limit, 0},
+		{"a changed limit starts a new bucket", "a", PerMinute(30, 1), 0},
+		{"unlimited never waits", "a", Limit{Rate: 1, Burst: 0}, 0},
+	}
+	for _, tt := range tests {
+		got, err := ms.Take(ctx, tt.key, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !closeTo(got, tt.want) {
+			t.Errorf("%s: wait = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreForgetsIdleBuckets(t *testing.T) {
+	ms, advance := newTestStore(time.Minute)
+	ctx := context.Background()
+	limit := PerMinute(1, 1)
+
+	ms.Take(ctx, "idle", limit)
+	advance(30 * time.Second)
+	ms.Take(ctx, "busy", limit)
+	advance(45 * time.Second)
+	ms.Take(ctx, "busy", limit)
+
+	if _, ok := ms.buckets["idle"]; ok {
+		t.Error("bucket idle for 75s was kept with an idle time of 1m")
+	}
+	if _, ok := ms.buckets["busy"]; !ok {
+		t.Error("bucket used 45s ago was removed")
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// AuditService appends entries to the "audit_logs" collection. Entries are never updated
+// or deleted by the application.
+type AuditService struct {
+	client *firestore.Client
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(client *firestore.Client) *AuditService {
+	return &AuditService{client: client}
+}
+
+// Record appends an entry to the audit log
+func (as *AuditService) Record(ctx context.Context, entry models.AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	// Create (not Set) so an existing entry can never be overwritten
+	doc := as.client.Collection("audit_logs").NewDoc()
+	_, err := doc.Create(ctx, entry)
+	if err != nil {
+		return err
+	}
+	logger.LogInfof("Audit: %s %s %s/%s by %s (%s)", doc.ID, entry.Action, entry.ResourceType, entry.ResourceID, entry.ActorUID, entry.ActorRole)
+	return nil
+}
+
+// AuditFilter selects audit entries; empty fields match everything. Combining filters with the
+// time range needs composite indexes on the filtered fields and timestamp.
+type AuditFilter struct {
+	ActorUID     string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	From         time.Time
+	To           time.Time
+}
+
+func (as *AuditService) query(f AuditFilter) firestore.Query {
+	q := as.client.Collection("audit_logs").Query
+	if f.ActorUID != "" {
+		q = q.Where("actorUid", "==", f.ActorUID)
+	}
+	if f.Action != "" {
+		q = q.Where("action", "==", f.Action)
+	}
+	if f.ResourceType != "" {
+		q = q.Where("resourceType", "==", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		q = q.Where("resourceId", "==", f.ResourceID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("timestamp", ">=", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("timestamp", "<", f.To)
+	}
+	return q.OrderBy("timestamp", firestore.Desc)
+}
+
+// List returns up to limit entries, newest first, starting after the entry with ID cursor.
+// The returned cursor is empty on the last page.
+func (as *AuditService) List(ctx context.Context, f AuditFilter, limit int, cursor string) ([]models.AuditEntry, string, error) {
+	q := as.query(f)
+	if cursor != "" {
+		snap, err := as.client.Collection("audit_logs").Doc(cursor).Get(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		q = q.StartAfter(snap)
+	}
+
+	docs, err := q.Limit(limit + 1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(docs) > limit {
+		docs = docs[:limit]
+		next = docs[limit-1].Ref.ID
+	}
+	entries := make([]models.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		entry, err := auditEntryFromDoc(doc)
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, next, nil
+}
+
+// Each calls fn for up to max matching entries, newest first, without loading them all at once
+func (as *AuditService) Each(ctx context.Context, f AuditFilter, max int, fn func(models.AuditEntry) error) error {
+	iter := as.query(f).Limit(max).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry, err := auditEntryFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+func auditEntryFromDoc(doc *firestore.DocumentSnapshot) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	if err := doc.DataTo(&entry); err != nil {
+		return entry, fmt.Errorf("failed to decode audit entry %s: %v", doc.Ref.ID, err)
+	}
+	entry.ID = doc.Ref.ID
+	return entry, nil
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Deletion task kinds
+const (
+	DeletionPet  = "pet"
+	DeletionUser = "user"
+)
+
+// Deletion task statuses
+const (
+	DeletionPending = "pending"
+	DeletionRunning = "running"
+	DeletionDone    = "done"
+	DeletionFailed  = "failed"
+)
+
+// DeletedUserID replaces the sender of chat messages written by a deleted user
+const DeletedUserID = "deleted-user"
+
+// ErrDeletionTaskNotFound is returned when a deletion task does not exist
+var ErrDeletionTaskNotFound = errors.New("deletion task not found")
+
+// DeletionService removes pets and user accounts together with everything that references them.
+// Work is done in chunks and every step is idempotent, so a task interrupted halfway (crash,
+// timeout, job retry) resumes where it stopped when it is run again.
+type DeletionService struct {
+	client    *firestore.Client
+	media     MediaDeleter
+	batchSize int
+}
+
+// NewDeletionService creates a new deletion service
+func NewDeletionService(client *firestore.Client, media MediaDeleter) *DeletionService {
+	if media == nil {
+		media = NoopMediaDeleter{}
+	}
+	return &DeletionService{
+		client:    client,
+		media:     media,
+		batchSize: 200,
+	}
+}
+
+func (ds *DeletionService) tasks() *firestore.CollectionRef {
+	return ds.client.Collection("deletion_tasks")
+}
+
+// CreateTask records a pending deletion of the given target. Task IDs are derived from the
+// target, so requesting the same deletion twice returns the existing task.
+func (ds *DeletionService) CreateTask(ctx context.Context, kind, targetID string) (*models.DeletionTask, error) {
+	if kind != DeletionPet && kind != DeletionUser {
+		return nil, fmt.Errorf("unknown deletion kind %q", kind)
+	}
+	ref := ds.tasks().Doc(kind + "_" + targetID)
+	now := time.Now()
+	task := &models.DeletionTask{
+		ID:         ref.ID,
+		Kind:       kind,
+		TargetID:   targetID,
+		Status:     DeletionPending,
+		Deleted:    map[string]int{},
+		Anonymized: map[string]int{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	_, err := ref.Create(ctx, task)
+	if status.Code(err) == codes.AlreadyExists {
+		return ds.GetTask(ctx, ref.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.LogInfof("Deletion task %s created", task.ID)
+	return task, nil
+}
+
+// GetTask returns a deletion task with its progress
+func (ds *DeletionService) GetTask(ctx context.Context, id string) (*models.DeletionTask, error) {
+	doc, err := ds.tasks().Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrDeletionTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	task := new(models.DeletionTask)
+	if err := doc.DataTo(task); err != nil {
+		return nil, err
+	}
+	task.ID = doc.Ref.ID
+	if task.Deleted == nil {
+		task.Deleted = map[string]int{}
+	}
+	if task.Anonymized == nil {
+		task.Anonymized = map[string]int{}
+	}
+	return task, nil
+}
+
+// Run executes a deletion task, saving progress after every chunk. Finished tasks are a no-op.
+func (ds *DeletionService) Run(ctx context.Context, id string) error {
+	task, err := ds.GetTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	if task.Status == DeletionDone {
+		return nil
+	}
+
+	task.Status = DeletionRunning
+	task.LastError = ""
+	if err := ds.save(ctx, task); err != nil {
+		return err
+	}
+
+	switch task.Kind {
+	case DeletionPet:
+		err = ds.purgePet(ctx, task, task.TargetID)
+	case DeletionUser:
+		err = ds.purgeUser(ctx, task, task.TargetID)
+	default:
+		err = fmt.Errorf("unknown deletion kind %q", task.Kind)
+	}
+
+	if err != nil {
+		task.Status = DeletionFailed
+		task.LastError = err.Error()
+		if saveErr := ds.save(context.Background(), task); saveErr != nil {
+			logger.LogErrorf("Failed to save deletion task %s: %v", task.ID, saveErr)
+		}
+		return err
+	}
+
+	task.Status = DeletionDone
+	task.CompletedAt = time.Now()
+	logger.LogInfof("Deletion task %s done: deleted=%v anonymized=%v", task.ID, task.Deleted, task.Anonymized)
+	return ds.save(ctx, task)
+}
+
+func (ds *DeletionService) save(ctx context.Context, task *models.DeletionTask) error {
+	task.UpdatedAt = time.Now()
+	_, err := ds.tasks().Doc(task.ID).Set(ctx, task)
+	return err
+}
+
+// purgePet deletes a pet, its updates and their media. Only media in the pet's folder, or
+// the owner's for the pet image, is deleted.
+func (ds *DeletionService) purgePet(ctx context.Context, task *models.DeletionTask, petID string) error {
+	updates := ds.client.Collection("pet_updates").Where("petId", "==", petID)
+	err := ds.deleteInChunks(ctx, task, "pet_updates", updates, func(doc *firestore.DocumentSnapshot) error {
+		imageURL, _ := doc.Data()["imageUrl"].(string)
+		return ds.deleteMedia(ctx, task, imageURL, PetMediaPrefix(petID))
+	})
+	if err != nil {
+		return err
+	}
+
+	ref := ds.client.Collection("pets").Doc(petID)
+	doc, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil // already purged
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get pet %s: %v", petID, err)
+	}
+	prefixes := []string{PetMediaPrefix(petID)}
+	if ownerID, _ := doc.Data()["ownerId"].(string); ownerID != "" {
+		prefixes = append(prefixes, UserMediaPrefix(ownerID))
+	}
+	imageURL, _ := doc.Data()["imageUrl"].(string)
+	if err := ds.deleteMedia(ctx, task, imageURL, prefixes...); err != nil {
+		return err
+	}
+	if _, err := ref.Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete pet %s: %v", petID, err)
+	}
+	task.Deleted["pets"]++
+	return ds.save(ctx, task)
+}
+
+// purgeUser deletes a user's pets, devices and preferences, anonymizes their chat messages,
+// removes them from their chat rooms and finally deletes the user document
+func (ds *DeletionService) purgeUser(ctx context.Context, task *models.DeletionTask, uid string) error {
+	pets, err := ds.client.Collection("pets").Where("ownerId", "==", uid).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to list pets of %s: %v", uid, err)
+	}
+	for _, pet := range pets {
+		if err := ds.purgePet(ctx, task, pet.Ref.ID); err != nil {
+			return err
+		}
+	}
+
+	devices := ds.client.Collection("users").Doc(uid).Collection("devices").Query
+	if err := ds.deleteInChunks(ctx, task, "devices", devices, func(doc *firestore.DocumentSnapshot) error {
+		return ds.releaseDevice(ctx, uid, doc.Ref.ID)
+	}); err != nil {
+		return err
+	}
+
+	if _, err := ds.client.Collection("notification_preferences").Doc(uid).Delete(ctx); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to delete notification preferences of %s: %v", uid, err)
+	}
+
+	rooms, err := ds.client.Collection("chats").Where("userIds", "array-contains", uid).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to list chats of %s: %v", uid, err)
+	}
+	for _, room := range rooms {
+		if err := ds.anonymizeMessages(ctx, task, room.Ref, uid); err != nil {
+			return err
+		}
+		if _, err := room.Ref.Update(ctx, []firestore.Update{
+			{Path: "userIds", Value: firestore.ArrayRemove(uid)},
+		}); err != nil {
+			return fmt.Errorf("failed to leave chat %s: %v", room.Ref.ID, err)
+		}
+		task.Anonymized["chats"]++
+		if err := ds.save(ctx, task); err != nil {
+			return err
+		}
+	}
+
+	userRef := ds.client.Collection("users").Doc(uid)
+	if doc, err := userRef.Get(ctx); err == nil {
+		photoURL, _ := doc.Data()["photoUrl"].(string)
+		if err := ds.deleteMedia(ctx, task, photoURL, UserMediaPrefix(uid)); err != nil {
+			return err
+		}
+	}
+	if _, err := userRef.Delete(ctx); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to delete user %s: %v", uid, err)
+	}
+	task.Deleted["users"]++
+	return ds.save(ctx, task)
+}
+
+// anonymizeMessages replaces the sender of the user's messages in a chat room. The content is
+// kept so the conversation stays readable for the other participants.
+func (ds *DeletionService) anonymizeMessages(ctx context.Context, task *models.DeletionTask, room *firestore.DocumentRef, uid string) error {
+	query := room.Collection("messages").Where("senderId", "==", uid).Limit(ds.batchSize)
+	for {
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to list messages in chat %s: %v", room.ID, err)
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		bw := ds.client.BulkWriter(ctx)
+		jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+		for _, doc := range docs {
+			job, err := bw.Update(doc.Ref, []firestore.Update{{Path: "senderId", Value: DeletedUserID}})
+			if err != nil {
+				bw.End()
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		bw.End()
+		if err := bulkResults(jobs); err != nil {
+			return fmt.Errorf("failed to anonymize messages in chat %s: %v", room.ID, err)
+		}
+
+		task.Anonymized["messages"] += len(docs)
+		if err := ds.save(ctx, task); err != nil {
+			return err
+		}
+	}
+}
+
+// releaseDevice deletes the record that a device token belongs to uid, unless the device
+// has been registered to another user since
+func (ds *DeletionService) releaseDevice(ctx context.Context, uid, token string) error {
+	ref := ds.client.Collection("device_owners").Doc(token)
+	doc, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get owner of device: %v", err)
+	}
+	if owner, _ := doc.DataAt("uid"); owner != uid {
+		return nil
+	}
+	if _, err := ref.Delete(ctx, firestore.LastUpdateTime(doc.UpdateTime)); err != nil && status.Code(err) != codes.FailedPrecondition {
+		return fmt.Errorf("failed to delete owner of device: %v", err)
+	}
+	return nil
+}
+
+// deleteInChunks deletes every document matching the query, batchSize documents at a time,
+// calling before (if set) for each document prior to deleting it
+func (ds *DeletionService) deleteInChunks(ctx context.Context, task *models.DeletionTask, name string, query firestore.Query, before func(*firestore.DocumentSnapshot) error) error {
+	query = query.Limit(ds.batchSize)
+	for {
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %v", name, err)
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		for _, doc := range docs {
+			if before != nil {
+				if err := before(doc); err != nil {
+					return err
+				}
+			}
+		}
+
+		bw := ds.client.BulkWriter(ctx)
+		jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+		for _, doc := range docs {
+			job, err := bw.Delete(doc.Ref)
+			if err != nil {
+				bw.End()
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		bw.End()
+		if err := bulkResults(jobs); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", name, err)
+		}
+
+		task.Deleted[name] += len(docs)
+		logger.LogInfof("Deletion task %s: deleted %d %s", task.ID, len(docs), name)
+		if err := ds.save(ctx, task); err != nil {
+			return err
+		}
+	}
+}
+
+// DeleteMedia removes a single uploaded file under one of the prefixes, e.g. a replaced
+// profile photo
+func (ds *DeletionService) DeleteMedia(ctx context.Context, mediaURL string, prefixes ...string) error {
+	if mediaURL == "" {
+		return nil
+	}
+	return ds.media.Delete(ctx, mediaURL, prefixes...)
+}
+
+// MediaAllowed reports whether a client supplied media URL may be stored on a resource
+// whose media is kept under the prefixes
+func (ds *DeletionService) MediaAllowed(mediaURL string, prefixes ...string) bool {
+	return mediaURL == "" || ds.media.Allows(mediaURL, prefixes...)
+}
+
+func (ds *DeletionService) deleteMedia(ctx context.Context, task *models.DeletionTask, mediaURL string, prefixes ...string) error {
+	if mediaURL == "" {
+		return nil
+	}
+	if err := ds.media.Delete(ctx, mediaURL, prefixes...); err != nil {
+		return fmt.Errorf("failed to delete media %s: %v", mediaURL, err)
+	}
+	task.Deleted["media"]++
+	return nil
+}
+
+// bulkResults waits for the bulk writer jobs and returns the first error
+func bulkResults(jobs []*firestore.BulkWriterJob) error {
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,505 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EmailMessage is a rendered email ready to be sent
+type EmailMessage struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// EmailSender delivers a single email
+type EmailSender interface {
+	Send(ctx context.Context, msg EmailMessage) error
+}
+
+// SMTPConfig holds the SMTP server settings
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender sends emails through an SMTP server, upgrading to TLS when offered
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender creates a new SMTP sender
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	return &SMTPSender{config: config}
+}
+
+// Send delivers the message as a multipart/alternative email
+func (s *SMTPSender) Send(ctx context.Context, msg EmailMessage) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %v", err)
+		}
+	}
+	if s.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+			if err := client.Auth(auth); err != nil {
+				return fmt.Errorf("failed to authenticate: %v", err)
+			}
+		}
+	}
+
+	body, err := buildMIMEMessage(s.config.From, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Mail(s.config.From); err != nil {
+		return fmt.Errorf("MAIL FROM failed: %v", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("RCPT TO failed: %v", err)
+	}
+	wc, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %v", err)
+	}
+	if _, err := wc.Write(body); err != nil {
+		wc.Close()
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to finish message: %v", err)
+	}
+	return client.Quit()
+}
+
+// buildMIMEMessage builds a multipart/alternative message with text and HTML parts
+func buildMIMEMessage(from string, msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.TextBody},
+		{"text/html; charset=utf-8", msg.HTMLBody},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReservationEmailData is the data for the reservation confirmation and check-in templates
+type ReservationEmailData struct {
+	OwnerName string
+	PetName   string
+	CheckIn   time.Time
+	CheckOut  time.Time
+}
+
+// DigestPet groups one pet's updates in the daily digest
+type DigestPet struct {
+	Name    string
+	Updates []models.PetUpdate
+}
+
+// DailyDigestEmailData is the data for the daily digest template
+type DailyDigestEmailData struct {
+	OwnerName string
+	Date      time.Time
+	Pets      []DigestPet
+}
+
+// InvoiceItem is a single line of an invoice
+type InvoiceItem struct {
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	Amount      int64  `json:"amount"` // in rupiah, for the whole line
+}
+
+// InvoiceEmailData is the data for the invoice template
+type InvoiceEmailData struct {
+	OwnerName     string        `json:"ownerName"`
+	InvoiceNumber string        `json:"invoiceNumber"`
+	IssuedAt      time.Time     `json:"issuedAt"`
+	DueAt         time.Time     `json:"dueAt"`
+	Items         []InvoiceItem `json:"items"`
+	Total         int64         `json:"total"` // in rupiah
+}
+
+// DigestScheduler queues the daily digests of the period from..to, e.g. as a background job
+type DigestScheduler func(ctx context.Context, from, to time.Time) error
+
+// EmailService renders templated emails and sends them. Sending blocks until the mail server
+// accepted the message, so callers run it from background jobs, which retry failures.
+type EmailService struct {
+	client     *firestore.Client
+	sender     EmailSender
+	templates  *emailTemplates
+	location   *time.Location // time zone of the owners, in which the digest hour applies
+	digestHour int
+	schedule   DigestScheduler
+	ticker     *time.Ticker
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewEmailService creates a new email service
+func NewEmailService(client *firestore.Client, sender EmailSender) (*EmailService, error) {
+	templates, err := newEmailTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &EmailService{
+		client:     client,
+		sender:     sender,
+		templates:  templates,
+		location:   wibLocation(),
+		digestHour: 20,
+		stopChan:   make(chan struct{}),
+	}, nil
+}
+
+// SetDigestScheduler sets how the daily digests are queued once they are due. Without a
+// scheduler no digests are sent.
+func (es *EmailService) SetDigestScheduler(schedule DigestScheduler) {
+	es.schedule = schedule
+}
+
+// Start begins the daily digest scheduler
+func (es *EmailService) Start() {
+	logger.LogInfo("Starting email service...")
+
+	// Check every 15 minutes whether the daily digest is due
+	es.ticker = time.NewTicker(15 * time.Minute)
+	es.wg.Add(1)
+	go func() {
+		defer es.wg.Done()
+		for {
+			select {
+			case now := <-es.ticker.C:
+				es.maybeScheduleDailyDigests(now)
+			case <-es.stopChan:
+				es.ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the daily digest scheduler. It is safe to call more than once.
+func (es *EmailService) Stop() {
+	es.stopOnce.Do(func() {
+		logger.LogInfo("Stopping email service...")
+		close(es.stopChan)
+		es.wg.Wait()
+	})
+}
+
+// SendTemplate renders the named template in the user's language and sends it to the user
+func (es *EmailService) SendTemplate(ctx context.Context, uid, name string, data interface{}) error {
+	user, err := es.getUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		logger.LogWarningf("User %s has no email address, skipping %s email", uid, name)
+		return nil
+	}
+	return es.sendTemplateTo(ctx, user, name, data)
+}
+
+func (es *EmailService) sendTemplateTo(ctx context.Context, user *models.User, name string, data interface{}) error {
+	subject, text, html, err := es.templates.Render(name, user.Language, data)
+	if err != nil {
+		return err
+	}
+	msg := EmailMessage{
+		To:       user.Email,
+		Subject:  subject,
+		TextBody: text,
+		HTMLBody: html,
+	}
+	if err := es.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send %s email to user %s: %v", name, user.ID, err)
+	}
+	logger.LogInfof("Email %q sent to user %s", msg.Subject, user.ID)
+	return nil
+}
+
+// SendReservationConfirmation emails the owner that the pet's stay is booked
+func (es *EmailService) SendReservationConfirmation(ctx context.Context, petID string) error {
+	return es.sendPetStayEmail(ctx, petID, EmailReservationConfirmation)
+}
+
+// SendCheckIn emails the owner that the pet has checked in
+func (es *EmailService) SendCheckIn(ctx context.Context, petID string) error {
+	return es.sendPetStayEmail(ctx, petID, EmailCheckIn)
+}
+
+func (es *EmailService) sendPetStayEmail(ctx context.Context, petID, name string) error {
+	pet, err := es.getPet(ctx, petID)
+	if err != nil {
+		return err
+	}
+	if pet.OwnerID == "" {
+		logger.LogWarningf("Pet %s has no owner, skipping %s email", petID, name)
+		return nil
+	}
+
+	user, err := es.getUser(ctx, pet.OwnerID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		logger.LogWarningf("User %s has no email address, skipping %s email", user.ID, name)
+		return nil
+	}
+	return es.sendTemplateTo(ctx, user, name, ReservationEmailData{
+		OwnerName: user.Name,
+		PetName:   pet.Name,
+		CheckIn:   pet.CheckIn,
+		CheckOut:  pet.CheckOut,
+	})
+}
+
+// SendInvoice emails an invoice to the given user
+func (es *EmailService) SendInvoice(ctx context.Context, uid string, invoice InvoiceEmailData) error {
+	user, err := es.getUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		logger.LogWarningf("User %s has no email address, skipping invoice email", uid)
+		return nil
+	}
+	invoice.OwnerName = user.Name
+	return es.sendTemplateTo(ctx, user, EmailInvoice, invoice)
+}
+
+// maybeScheduleDailyDigests queues the daily digest once per day after the configured hour
+// in the owners' time zone
+func (es *EmailService) maybeScheduleDailyDigests(now time.Time) {
+	now = now.In(es.location)
+	if now.Hour() < es.digestHour {
+		return
+	}
+	if es.schedule == nil {
+		logger.LogWarning("No digest scheduler set, skipping daily digests")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	date := now.Format("2006-01-02")
+	claimed, err := es.claimDailyDigest(ctx, date)
+	if err != nil {
+		logger.LogErrorf("Failed to claim daily digest: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	if err := es.schedule(ctx, now.Add(-24*time.Hour), now); err != nil {
+		// Release the claim so that the next tick, here or on another instance, tries again
+		logger.LogErrorf("Failed to schedule daily digests for %s: %v", date, err)
+		if _, err := es.dailyDigest(date).Delete(ctx); err != nil {
+			logger.LogErrorf("Failed to release daily digest claim for %s: %v", date, err)
+		}
+		return
+	}
+	logger.LogInfof("Daily digests for %s scheduled", date)
+}
+
+func (es *EmailService) dailyDigest(date string) *firestore.DocumentRef {
+	return es.client.Collection("daily_digests").Doc(date)
+}
+
+// claimDailyDigest records that the digest of date is being scheduled and reports whether
+// it was not recorded before. The claim is kept in Firestore so that only one instance
+// schedules each digest, also across restarts.
+func (es *EmailService) claimDailyDigest(ctx context.Context, date string) (bool, error) {
+	_, err := es.dailyDigest(date).Create(ctx, map[string]interface{}{"claimedAt": time.Now()})
+	if status.Code(err) == codes.AlreadyExists {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DigestOwners returns the owners whose pets had updates in the given period, with the IDs
+// of those pets
+func (es *EmailService) DigestOwners(ctx context.Context, from, to time.Time) (map[string][]string, error) {
+	docs, err := es.client.Collection("pet_updates").
+		Where("timestamp", ">=", from).
+		Where("timestamp", "<", to).
+		OrderBy("timestamp", firestore.Asc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pet updates: %v", err)
+	}
+
+	petIDs := make(map[string]bool)
+	for _, doc := range docs {
+		if petID, _ := doc.Data()["petId"].(string); petID != "" {
+			petIDs[petID] = true
+		}
+	}
+
+	owners := make(map[string][]string)
+	for petID := range petIDs {
+		pet, err := es.getPet(ctx, petID)
+		if err != nil {
+			logger.LogErrorf("Failed to get pet %s for digest: %v", petID, err)
+			continue
+		}
+		if pet.OwnerID == "" || pet.Deleted {
+			continue
+		}
+		owners[pet.OwnerID] = append(owners[pet.OwnerID], petID)
+	}
+	logger.LogInfof("%d owner(s) have pet updates for the digest of %s", len(owners), to.In(es.location).Format("2006-01-02"))
+	return owners, nil
+}
+
+// SendDailyDigest emails an owner a summary of the given pets' updates in the period
+func (es *EmailService) SendDailyDigest(ctx context.Context, uid string, petIDs []string, from, to time.Time) error {
+	user, err := es.getUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return nil
+	}
+
+	var pets []DigestPet
+	for _, petID := range petIDs {
+		pet, err := es.getPet(ctx, petID)
+		if err != nil {
+			return err
+		}
+		if pet.OwnerID != uid || pet.Deleted {
+			continue
+		}
+
+		docs, err := es.client.Collection("pet_updates").Where("petId", "==", petID).Documents(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to fetch updates of pet %s: %v", petID, err)
+		}
+		var updates []models.PetUpdate
+		for _, doc := range docs {
+			var update models.PetUpdate
+			if err := doc.DataTo(&update); err != nil {
+				logger.LogErrorf("Error decoding pet update %s: %v", doc.Ref.ID, err)
+				continue
+			}
+			if update.Timestamp.Before(from) || !update.Timestamp.Before(to) {
+				continue
+			}
+			update.ID = doc.Ref.ID
+			updates = append(updates, update)
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		sort.Slice(updates, func(i, j int) bool { return updates[i].Timestamp.Before(updates[j].Timestamp) })
+		pets = append(pets, DigestPet{Name: pet.Name, Updates: updates})
+	}
+	if len(pets) == 0 {
+		return nil
+	}
+	sort.Slice(pets, func(i, j int) bool { return pets[i].Name < pets[j].Name })
+
+	return es.sendTemplateTo(ctx, user, EmailDailyDigest, DailyDigestEmailData{
+		OwnerName: user.Name,
+		Date:      to,
+		Pets:      pets,
+	})
+}
+
+func (es *EmailService) getPet(ctx context.Context, petID string) (*models.Pet, error) {
+	doc, err := es.client.Collection("pets").Doc(petID).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pet %s: %v", petID, err)
+	}
+	pet := new(models.Pet)
+	if err := doc.DataTo(pet); err != nil {
+		return nil, fmt.Errorf("failed to decode pet %s: %v", petID, err)
+	}
+	return pet, nil
+}
+
+func (es *EmailService) getUser(ctx context.Context, uid string) (*models.User, error) {
+	doc, err := es.client.Collection("users").Doc(uid).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %v", uid, err)
+	}
+	user := new(models.User)
+	if err := doc.DataTo(user); err != nil {
+		return nil, fmt.Errorf("failed to decode user %s: %v", uid, err)
+	}
+	user.ID = uid
+	return user, nil
+}
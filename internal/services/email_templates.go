@@ -0,0 +1,162 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+// Email template names, each with a .txt (including the subject) and .html file per language
+const (
+	EmailReservationConfirmation = "reservation_confirmation"
+	EmailCheckIn                 = "check_in"
+	EmailDailyDigest             = "daily_digest"
+	EmailInvoice                 = "invoice"
+)
+
+// Supported email languages
+const (
+	LanguageIndonesian = "id"
+	LanguageEnglish    = "en"
+)
+
+// DefaultEmailLanguage is used when a user has no language set
+const DefaultEmailLanguage = LanguageIndonesian
+
+var emailTemplateNames = []string{EmailReservationConfirmation, EmailCheckIn, EmailDailyDigest, EmailInvoice}
+
+var indonesianMonths = []string{"Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+// emailTemplate holds the parsed text and HTML variants of one template in one language
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// emailTemplates renders the embedded email templates
+type emailTemplates struct {
+	templates map[string]map[string]emailTemplate // language -> name -> template
+}
+
+// newEmailTemplates parses all embedded templates for every supported language
+func newEmailTemplates() (*emailTemplates, error) {
+	et := &emailTemplates{templates: make(map[string]map[string]emailTemplate)}
+
+	for _, lang := range []string{LanguageIndonesian, LanguageEnglish} {
+		funcs := emailTemplateFuncs(lang)
+		et.templates[lang] = make(map[string]emailTemplate)
+
+		for _, name := range emailTemplateNames {
+			base := "templates/email/" + lang + "/" + name
+			text, err := texttemplate.New(name+".txt").Funcs(funcs).ParseFS(emailTemplateFS, base+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s.txt: %v", base, err)
+			}
+			if text.Lookup("subject") == nil {
+				return nil, fmt.Errorf("template %s.txt does not define a subject", base)
+			}
+			html, err := htmltemplate.New(name+".html").Funcs(funcs).ParseFS(emailTemplateFS, base+".html")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s.html: %v", base, err)
+			}
+			et.templates[lang][name] = emailTemplate{text: text, html: html}
+		}
+	}
+	return et, nil
+}
+
+// Render renders the named template in the given language, falling back to the default language
+func (et *emailTemplates) Render(name, lang string, data interface{}) (subject, textBody, htmlBody string, err error) {
+	byName, ok := et.templates[lang]
+	if !ok {
+		byName = et.templates[DefaultEmailLanguage]
+	}
+	tmpl, ok := byName[name]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown email template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render subject: %v", err)
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := tmpl.text.Execute(&buf, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render text body: %v", err)
+	}
+	textBody = buf.String()
+
+	buf.Reset()
+	if err := tmpl.html.Execute(&buf, data); err != nil {
+		return "", "", "", fmt.Errorf("failed to render HTML body: %v", err)
+	}
+	htmlBody = buf.String()
+
+	return subject, textBody, htmlBody, nil
+}
+
+// wibLocation returns the hotel's time zone, Western Indonesian Time
+func wibLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		loc = time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// emailTemplateFuncs returns date and money formatting helpers for the given language
+func emailTemplateFuncs(lang string) map[string]interface{} {
+	loc := wibLocation()
+
+	date := func(t time.Time) string {
+		t = t.In(loc)
+		if lang == LanguageIndonesian {
+			return fmt.Sprintf("%d %s %d", t.Day(), indonesianMonths[t.Month()-1], t.Year())
+		}
+		return t.Format("2 January 2006")
+	}
+
+	return map[string]interface{}{
+		"date": date,
+		"time": func(t time.Time) string {
+			return t.In(loc).Format("15:04")
+		},
+		"datetime": func(t time.Time) string {
+			return date(t) + " " + t.In(loc).Format("15:04") + " WIB"
+		},
+		"money": func(amount int64) string {
+			if lang == LanguageIndonesian {
+				return "Rp" + groupThousands(amount, ".")
+			}
+			return "IDR " + groupThousands(amount, ",")
+		},
+	}
+}
+
+// groupThousands formats an integer with the given thousands separator
+func groupThousands(n int64, sep string) string {
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	digits := strconv.FormatInt(n, 10)
+	var parts []string
+	for len(digits) > 3 {
+		parts = append([]string{digits[len(digits)-3:]}, parts...)
+		digits = digits[:len(digits)-3]
+	}
+	parts = append([]string{digits}, parts...)
+	return sign + strings.Join(parts, sep)
+}
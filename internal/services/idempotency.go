@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+)
+
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// ErrIdempotencyKeyReused is returned when a key is sent again with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+
+// ErrIdempotencyInProgress is returned while the first request with a key is still running
+var ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+
+// ErrIdempotencyClaimLost is returned when completing or releasing a key whose claim expired
+// and was taken over by another request
+var ErrIdempotencyClaimLost = errors.New("idempotency key was claimed by another request")
+
+// IdempotencyService stores the first response to a request per caller and Idempotency-Key.
+// Expired records are ignored and left for the store to remove.
+type IdempotencyService struct {
+	store IdempotencyStore
+	ttl   time.Duration
+	lock  time.Duration
+	now   func() time.Time
+}
+
+// NewIdempotencyService creates an idempotency service keeping responses for ttl. A
+// request still processing after lock is considered abandoned and may be retried.
+func NewIdempotencyService(store IdempotencyStore, ttl, lock time.Duration) *IdempotencyService {
+	return &IdempotencyService{store: store, ttl: ttl, lock: lock, now: time.Now}
+}
+
+func idempotencyID(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newClaimToken returns a random token identifying one claim on a key
+func newClaimToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Begin claims key for a request. It returns the claim token if the caller should process
+// the request, the stored record if it was already completed, ErrIdempotencyKeyReused if
+// the key belongs to a different request and ErrIdempotencyInProgress while another
+// request holds it.
+func (is *IdempotencyService) Begin(ctx context.Context, scope, key, requestHash string) (*models.IdempotencyRecord, string, error) {
+	id := idempotencyID(scope, key)
+	token, err := newClaimToken()
+	if err != nil {
+		return nil, "", err
+	}
+	var completed *models.IdempotencyRecord
+	err = is.store.Update(ctx, id, func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		completed = nil // the update function may be retried
+		now := is.now()
+		if record != nil && now.Before(record.ExpiresAt) {
+			if record.RequestHash != requestHash {
+				return nil, ErrIdempotencyKeyReused
+			}
+			if record.Status == IdempotencyCompleted {
+				record.ID = id
+				completed = record
+				return nil, errIdempotencyUnchanged
+			}
+			if now.Before(record.LockedUntil) {
+				return nil, ErrIdempotencyInProgress
+			}
+			logger.LogWarningf("Taking over abandoned idempotency key %s", id)
+		}
+		return &models.IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: requestHash,
+			Status:      IdempotencyProcessing,
+			ClaimToken:  token,
+			LockedUntil: now.Add(is.lock),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(is.ttl),
+		}, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if completed != nil {
+		return completed, "", nil
+	}
+	return nil, token, nil
+}
+
+// Complete stores the response to the request that claimed key with token
+func (is *IdempotencyService) Complete(ctx context.Context, scope, key, token string, statusCode int, contentType, location string, body []byte) error {
+	return is.store.Update(ctx, idempotencyID(scope, key), func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		if record == nil || record.Status != IdempotencyProcessing || record.ClaimToken != token {
+			return nil, ErrIdempotencyClaimLost
+		}
+		record.Status = IdempotencyCompleted
+		record.StatusCode = statusCode
+		record.ContentType = contentType
+		record.Location = location
+		record.Body = body
+		return record, nil
+	})
+}
+
+// Release gives up the claim on key made with token without storing a response, so that
+// a retry is processed again
+func (is *IdempotencyService) Release(ctx context.Context, scope, key, token string) error {
+	return is.store.Update(ctx, idempotencyID(scope, key), func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		if record == nil || record.Status != IdempotencyProcessing || record.ClaimToken != token {
+			return nil, ErrIdempotencyClaimLost
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"pawtroli-be/internal/models"
+)
+
+// errIdempotencyUnchanged is returned by an IdempotencyStore.Update function to leave the
+// record as it is without failing the update
+var errIdempotencyUnchanged = errors.New("idempotency record unchanged")
+
+// IdempotencyStore persists idempotency records for the IdempotencyService
+type IdempotencyStore interface {
+	// Update atomically reads the record id, nil if there is none, and stores the record fn
+	// returns, deleting it if fn returns nil. An error from fn leaves the record unchanged
+	// and is returned, except errIdempotencyUnchanged which makes Update return nil.
+	Update(ctx context.Context, id string, fn func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)) error
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore for tests and local development
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore creates a new in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]models.IdempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) Update(ctx context.Context, id string, fn func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current *models.IdempotencyRecord
+	if record, ok := s.records[id]; ok {
+		current = &record
+	}
+	next, err := fn(current)
+	if errors.Is(err, errIdempotencyUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		delete(s.records, id)
+		return nil
+	}
+	s.records[id] = *next
+	return nil
+}
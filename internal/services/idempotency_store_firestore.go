@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirestoreIdempotencyStore stores idempotency records in the "idempotency_keys"
+// collection. A Firestore TTL policy on expiresAt removes expired records.
+type FirestoreIdempotencyStore struct {
+	client *firestore.Client
+}
+
+// NewFirestoreIdempotencyStore creates a new Firestore backed idempotency store
+func NewFirestoreIdempotencyStore(client *firestore.Client) *FirestoreIdempotencyStore {
+	return &FirestoreIdempotencyStore{client: client}
+}
+
+func (s *FirestoreIdempotencyStore) Update(ctx context.Context, id string, fn func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)) error {
+	start := time.Now()
+	ref := s.client.Collection("idempotency_keys").Doc(id)
+	var fnErr error
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		var current *models.IdempotencyRecord
+		if err == nil {
+			current = new(models.IdempotencyRecord)
+			if err := snap.DataTo(current); err != nil {
+				return err
+			}
+		}
+		next, err := fn(current)
+		if fnErr = err; err != nil {
+			return err
+		}
+		if next == nil {
+			return tx.Delete(ref)
+		}
+		return tx.Set(ref, next)
+	})
+	// Errors of fn are outcomes, not failures of the operation
+	logger.LogFirestoreOperation("UPDATE", "idempotency_keys", id, err == nil || err == fnErr, time.Since(start))
+	if errors.Is(err, errIdempotencyUnchanged) {
+		return nil
+	}
+	return err
+}
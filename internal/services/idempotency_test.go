@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestIdempotencyService keeps responses for an hour with a one minute lock, on a clock
+// that only moves when the returned function is called
+func newTestIdempotencyService() (*IdempotencyService, func(time.Duration)) {
+	is := NewIdempotencyService(NewMemoryIdempotencyStore(), time.Hour, time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	is.now = func() time.Time { return now }
+	return is, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestIdempotencyBegin(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// setup claims the key for hash "first" and leaves it in the state under test
+		setup      func(is *IdempotencyService, advance func(time.Duration), token string)
+		hash       string
+		wantErr    error
+		wantReplay bool
+		wantClaim  bool
+	}{
+		{
+			name:    "retry while in progress conflicts",
+			setup:   func(is *IdempotencyService, advance func(time.Duration), token string) {},
+			hash:    "first",
+			wantErr: ErrIdempotencyInProgress,
+		},
+		{
+			name: "completed request is replayed",
+			setup: func(is *IdempotencyService, advance func(time.Duration), token string) {
+				is.Complete(ctx, "uid", "key", token, 201, "application/json", "/pets/1", []byte(`{"id":"1"}`))
+			},
+			hash:       "first",
+			wantReplay: true,
+		},
+		{
+			name: "different request with the key is rejected",
+			setup: func(is *IdempotencyService, advance func(time.Duration), token string) {
+				is.Complete(ctx, "uid", "key", token, 201, "application/json", "", nil)
+			},
+			hash:    "second",
+			wantErr: ErrIdempotencyKeyReused,
+		},
+		{
+			name:    "different request while in progress is rejected",
+			setup:   func(is *IdempotencyService, advance func(time.Duration), token string) {},
+			hash:    "second",
+			wantErr: ErrIdempotencyKeyReused,
+		},
+		{
+			name: "released key is claimed again",
+			setup: func(is *IdempotencyService, advance func(time.Duration), token string) {
+				is.Release(ctx, "uid", "key", token)
+			},
+			hash:      "first",
+			wantClaim: true,
+		},
+		{
+			name:      "abandoned claim is taken over",
+			setup:     func(is *IdempotencyService, advance func(time.Duration), token string) { advance(2 * time.Minute) },
+			hash:      "first",
+			wantClaim: true,
+		},
+		{
+			name: "expired response is not replayed",
+			setup: func(is *IdempotencyService, advance func(time.Duration), token string) {
+				is.Complete(ctx, "uid", "key", token, 201, "application/json", "", nil)
+				advance(2 * time.Hour)
+			},
+			hash:      "second",
+			wantClaim: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is, advance := newTestIdempotencyService()
+			_, token, err := is.Begin(ctx, "uid", "key", "first")
+			if err != nil || token == "" {
+				t.Fatalf("first Begin = %q, %v; want a claim", token, err)
+			}
+			tt.setup(is, advance, token)
+
+			record, retryToken, err := is.Begin(ctx, "uid", "key", tt.hash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Begin error = %v, want %v", err, tt.wantErr)
+			}
+			if got := record != nil; got != tt.wantReplay {
+				t.Errorf("replayed = %v, want %v", got, tt.wantReplay)
+			}
+			if tt.wantReplay && (record.StatusCode != 201 || record.Location != "/pets/1" || string(record.Body) != `{"id":"1"}`) {
+				t.Errorf("replayed %d %q %s, want the stored response", record.StatusCode, record.Location, record.Body)
+			}
+			if got := retryToken != ""; got != tt.wantClaim {
+				t.Errorf("claimed = %v, want %v", got, tt.wantClaim)
+			}
+			if tt.wantClaim && retryToken == token {
+				t.Error("a new claim reused the previous claim token")
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeysAreScopedToTheCaller(t *testing.T) {
+	ctx := context.Background()
+	is, _ := newTestIdempotencyService()
+	if _, _, err := is.Begin(ctx, "alice", "key", "hash"); err != nil {
+		t.Fatalf("Begin for alice: %v", err)
+	}
+	if _, token, err := is.Begin(ctx, "bob", "key", "hash"); err != nil || token == "" {
+		t.Errorf("Begin for bob with the same key = %q, %v; want a claim", token, err)
+	}
+}
+
+func TestIdempotencyOverrunClaimCannotTouchTheNewClaim(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		finish func(is *IdempotencyService, token string) error
+	}{
+		{"complete", func(is *IdempotencyService, token string) error {
+			return is.Complete(ctx, "uid", "key", token, 200, "application/json", "", []byte("stale"))
+		}},
+		{"release", func(is *IdempotencyService, token string) error {
+			return is.Release(ctx, "uid", "key", token)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is, advance := newTestIdempotencyService()
+			_, stale, _ := is.Begin(ctx, "uid", "key", "hash")
+			advance(2 * time.Minute) // the first request outlasts its lock
+			_, current, err := is.Begin(ctx, "uid", "key", "hash")
+			if err != nil || current == "" {
+				t.Fatalf("takeover Begin = %q, %v; want a claim", current, err)
+			}
+
+			if err := tt.finish(is, stale); !errors.Is(err, ErrIdempotencyClaimLost) {
+				t.Errorf("%s with the overrun claim = %v, want ErrIdempotencyClaimLost", tt.name, err)
+			}
+			if _, _, err := is.Begin(ctx, "uid", "key", "hash"); !errors.Is(err, ErrIdempotencyInProgress) {
+				t.Errorf("Begin after the overrun %s = %v, want the new claim still in progress", tt.name, err)
+			}
+
+			if err := is.Complete(ctx, "uid", "key", current, 201, "application/json", "", []byte("fresh")); err != nil {
+				t.Fatalf("Complete with the current claim: %v", err)
+			}
+			record, _, err := is.Begin(ctx, "uid", "key", "hash")
+			if err != nil || record == nil || string(record.Body) != "fresh" {
+				t.Errorf("replay after the current claim completed = %v, %v; want its response", record, err)
+			}
+			if err := is.Release(ctx, "uid", "key", current); !errors.Is(err, ErrIdempotencyClaimLost) {
+				t.Errorf("Release after Complete = %v, want ErrIdempotencyClaimLost", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+)
+
+// jobSaveTimeout bounds saving the outcome of a job run. Claims last for the job timeout plus
+// this, so another instance never requeues a job whose run is still being recorded.
+const jobSaveTimeout = 30 * time.Second
+
+// JobHandler processes a single job; returning an error schedules a retry
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// jobType is a registered job type with its handler and concurrency limit
+type jobType struct {
+	handler       JobHandler
+	maxConcurrent int
+	running       int
+}
+
+// JobQueueConfig holds the tuning options of the JobQueue
+type JobQueueConfig struct {
+	Concurrency  int           // maximum jobs running at once across all types
+	MaxAttempts  int           // attempts before a job is moved to the dead letter status
+	BaseBackoff  time.Duration // delay before the first retry, doubled for every attempt
+	MaxBackoff   time.Duration // upper bound of the retry delay
+	JobTimeout   time.Duration // deadline of a single job run, which the claim lease outlasts
+	PollInterval time.Duration // how often the store is polled for due jobs
+}
+
+// DefaultJobQueueConfig returns the default JobQueue settings
+func DefaultJobQueueConfig() JobQueueConfig {
+	return JobQueueConfig{
+		Concurrency:  10,
+		MaxAttempts:  8,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   1 * time.Hour,
+		JobTimeout:   2 * time.Minute,
+		PollInterval: 2 * time.Second,
+	}
+}
+
+// JobQueue runs typed background jobs persisted in a JobStore, retrying failures with
+// exponential backoff and moving jobs that keep failing to the dead letter status
+type JobQueue struct {
+	store    JobStore
+	config   JobQueueConfig
+	mu       sync.Mutex
+	types    map[string]*jobType
+	running  int
+	wake     chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewJobQueue creates a new job queue
+func NewJobQueue(store JobStore, config JobQueueConfig) *JobQueue {
+	return &JobQueue{
+		store:    store,
+		config:   config,
+		types:    make(map[string]*jobType),
+		wake:     make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// RegisterHandler registers the handler of a job type. maxConcurrent limits how many jobs of
+// this type run at once; 0 means only the global limit applies.
+func (q *JobQueue) RegisterHandler(name string, handler JobHandler, maxConcurrent int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if maxConcurrent <= 0 {
+		maxConcurrent = q.config.Concurrency
+	}
+	q.types[name] = &jobType{handler: handler, maxConcurrent: maxConcurrent}
+}
+
+// Enqueue stores a new job with the JSON encoded payload and runs it as soon as possible
+func (q *JobQueue) Enqueue(ctx context.Context, name string, payload interface{}) (*models.Job, error) {
+	return q.EnqueueAt(ctx, name, payload, time.Now())
+}
+
+// EnqueueAt stores a new job with the JSON encoded payload to be run at the given time
+func (q *JobQueue) EnqueueAt(ctx context.Context, name string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %v", err)
+	}
+
+	now := time.Now()
+	job := &models.Job{
+		Type:        name,
+		Payload:     string(data),
+		Status:      JobPending,
+		MaxAttempts: q.config.MaxAttempts,
+		RunAt:       runAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := q.store.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	logger.LogDebugf("Job %s (%s) enqueued", job.ID, job.Type)
+
+	q.notify()
+	return job, nil
+}
+
+// Get returns a single job
+func (q *JobQueue) Get(ctx context.Context, id string) (*models.Job, error) {
+	return q.store.Get(ctx, id)
+}
+
+// List returns recent jobs, optionally filtered by status
+func (q *JobQueue) List(ctx context.Context, status string, limit int) ([]*models.Job, error) {
+	return q.store.List(ctx, status, limit)
+}
+
+// Retry resets a dead job so it is attempted again from scratch
+func (q *JobQueue) Retry(ctx context.Context, id string) (*models.Job, error) {
+	job, err := q.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != JobDead {
+		return nil, fmt.Errorf("job %s is %s, only dead jobs can be retried", id, job.Status)
+	}
+
+	now := time.Now()
+	job.Status = JobPending
+	job.Attempts = 0
+	job.RunAt = now
+	job.UpdatedAt = now
+	if err := q.store.Update(ctx, job); err != nil {
+		return nil, err
+	}
+	logger.LogInfof("Job %s (%s) requeued for retry", job.ID, job.Type)
+
+	q.notify()
+	return job, nil
+}
+
+// Start begins polling the store and running due jobs
+func (q *JobQueue) Start() {
+	logger.LogInfo("Starting job queue...")
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		ticker := time.NewTicker(q.config.PollInterval)
+		defer ticker.Stop()
+
+		lastRequeue := time.Time{}
+		for {
+			// Recover jobs left running by a crashed instance once per lease period
+			if time.Since(lastRequeue) > q.config.JobTimeout {
+				q.requeueExpired()
+				lastRequeue = time.Now()
+			}
+			q.dispatch()
+
+			select {
+			case <-ticker.C:
+			case <-q.wake:
+			case <-q.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for running jobs to finish. It is safe to call more than once.
+func (q *JobQueue) Stop() {
+	q.stopOnce.Do(func() {
+		logger.LogInfo("Stopping job queue...")
+		close(q.stopChan)
+		q.wg.Wait()
+	})
+}
+
+func (q *JobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueue) requeueExpired() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	n, err := q.store.RequeueExpired(ctx, time.Now())
+	if err != nil {
+		logger.LogErrorf("Failed to requeue expired jobs: %v", err)
+		return
+	}
+	if n > 0 {
+		logger.LogWarningf("Requeued %d job(s) with expired leases", n)
+	}
+}
+
+// dispatch claims due jobs for every type with free capacity and runs them
+func (q *JobQueue) dispatch() {
+	q.mu.Lock()
+	names := make([]string, 0, len(q.types))
+	for name := range q.types {
+		names = append(names, name)
+	}
+	q.mu.Unlock()
+
+	for _, name := range names {
+		free := q.freeSlots(name)
+		if free <= 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		jobs, err := q.store.ClaimDue(ctx, name, time.Now(), q.config.JobTimeout+jobSaveTimeout, free)
+		cancel()
+		if err != nil {
+			logger.LogErrorf("Failed to claim %s jobs: %v", name, err)
+			continue
+		}
+
+		for _, job := range jobs {
+			q.mu.Lock()
+			t := q.types[name]
+			t.running++
+			q.running++
+			q.mu.Unlock()
+
+			q.wg.Add(1)
+			go func(job *models.Job, t *jobType) {
+				defer q.wg.Done()
+				q.run(job, t.handler)
+
+				q.mu.Lock()
+				t.running--
+				q.running--
+				q.mu.Unlock()
+				q.notify()
+			}(job, t)
+		}
+	}
+}
+
+func (q *JobQueue) freeSlots(name string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.types[name]
+	free := t.maxConcurrent - t.running
+	if global := q.config.Concurrency - q.running; global < free {
+		free = global
+	}
+	return free
+}
+
+// run executes one job and records its outcome
+func (q *JobQueue) run(job *models.Job, handler JobHandler) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), q.config.JobTimeout)
+	err := runJobHandler(ctx, handler, job)
+	cancel()
+
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	if err == nil {
+		job.Status = JobSucceeded
+		job.LastError = ""
+		logger.LogInfof("Job %s (%s) succeeded in %v", job.ID, job.Type, time.Since(start))
+	} else {
+		job.LastError = err.Error()
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = JobDead
+			logger.LogErrorf("Job %s (%s) failed permanently after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		} else {
+			job.Status = JobPending
+			job.RunAt = time.Now().Add(q.backoff(job.Attempts))
+			logger.LogWarningf("Job %s (%s) failed (attempt %d/%d), retrying at %s: %v",
+				job.ID, job.Type, job.Attempts, job.MaxAttempts, job.RunAt.Format(time.RFC3339), err)
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), jobSaveTimeout)
+	defer cancel()
+	if err := q.store.Update(ctx, job); err != nil {
+		logger.LogErrorf("Failed to save result of job %s (%s): %v", job.ID, job.Type, err)
+	}
+}
+
+// runJobHandler calls the handler, turning a panic into an error so the job is retried
+func runJobHandler(ctx context.Context, handler JobHandler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff returns the delay before the given retry attempt, with up to 20% jitter
+func (q *JobQueue) backoff(attempts int) time.Duration {
+	delay := q.config.BaseBackoff
+	for i := 1; i < attempts && delay < q.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.config.MaxBackoff {
+		delay = q.config.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
+
+// DecodeJobPayload decodes the JSON payload of a job into v
+func DecodeJobPayload(job *models.Job, v interface{}) error {
+	if err := json.Unmarshal([]byte(job.Payload), v); err != nil {
+		return fmt.Errorf("invalid %s payload: %v", job.Type, err)
+	}
+	return nil
+}
+
+// EncodeJobPayload replaces the payload of a running job with v. The payload is saved with
+// the outcome of the attempt, so handlers can record progress that retries should skip.
+func EncodeJobPayload(job *models.Job, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	job.Payload = string(data)
+	return nil
+}
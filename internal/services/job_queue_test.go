@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pawtroli-be/internal/models"
+)
+
+// testJobQueueConfig retries quickly so that tests run through every attempt in milliseconds
+func testJobQueueConfig() JobQueueConfig {
+	return JobQueueConfig{
+		Concurrency:  4,
+		MaxAttempts:  3,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   40 * time.Millisecond,
+		JobTimeout:   time.Second,
+		PollInterval: 5 * time.Millisecond,
+	}
+}
+
+// startJobQueue starts a queue on a fresh in-memory store, stopping it when the test ends
+func startJobQueue(t *testing.T, config JobQueueConfig, register func(q *JobQueue)) (*JobQueue, *MemoryJobStore) {
+	t.Helper()
+	store := NewMemoryJobStore()
+	q := NewJobQueue(store, config)
+	register(q)
+	q.Start()
+	t.Cleanup(q.Stop)
+	return q, store
+}
+
+// waitForJob polls the store until the job has the given status
+func waitForJob(t *testing.T, store JobStore, id, status string) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := store.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s after 5s, want %s", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobQueueRunsEveryJobOnce(t *testing.T) {
+	var mu sync.Mutex
+	runs := map[string]int{}
+	q, store := startJobQueue(t, testJobQueueConfig(), func(q *JobQueue) {
+		q.RegisterHandler("count", func(ctx context.Context, job *models.Job) error {
+			mu.Lock()
+			runs[job.ID]++
+			mu.Unlock()
+			return nil
+		}, 0)
+	})
+
+	var ids []string
+	for i := 0; i < 20; i++ {
+		job, err := q.Enqueue(context.Background(), "count", map[string]int{"n": i})
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		job := waitForJob(t, store, id, JobSucceeded)
+		if job.Attempts != 1 {
+			t.Errorf("job %s took %d attempts, want 1", id, job.Attempts)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range ids {
+		if runs[id] != 1 {
+			t.Errorf("job %s ran %d times, want 1", id, runs[id])
+		}
+	}
+}
+
+func TestJobQueueRetriesFailedJobs(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int // attempts that fail before the handler succeeds
+		wantStatus   string
+		wantAttempts int
+	}{
+		{"succeeds first time", 0, JobSucceeded, 1},
+		{"succeeds on retry", 2, JobSucceeded, 3},
+		{"dead letters after max attempts", 5, JobDead, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			var mu sync.Mutex
+			var times []time.Time
+			q, store := startJobQueue(t, testJobQueueConfig(), func(q *JobQueue) {
+				q.RegisterHandler("flaky", func(ctx context.Context, job *models.Job) error {
+					mu.Lock()
+					times = append(times, time.Now())
+					mu.Unlock()
+					if int(calls.Add(1)) <= tt.failures {
+						return errors.New("temporary failure")
+					}
+					return nil
+				}, 0)
+			})
+
+			job, err := q.Enqueue(context.Background(), "flaky", nil)
+			if err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			job = waitForJob(t, store, job.ID, tt.wantStatus)
+			q.Stop()
+
+			if job.Attempts != tt.wantAttempts || int(calls.Load()) != tt.wantAttempts {
+				t.Errorf("attempts = %d, handler calls = %d, want %d", job.Attempts, calls.Load(), tt.wantAttempts)
+			}
+			if tt.wantStatus == JobDead && job.LastError != "temporary failure" {
+				t.Errorf("lastError = %q, want the handler's error", job.LastError)
+			}
+			if tt.wantStatus == JobSucceeded && job.LastError != "" {
+				t.Errorf("lastError = %q after success, want none", job.LastError)
+			}
+
+			// Every retry waits at least the backoff of the attempt before it
+			config := testJobQueueConfig()
+			for i := 1; i < len(times); i++ {
+				want := config.BaseBackoff << (i - 1)
+				if want > config.MaxBackoff {
+					want = config.MaxBackoff
+				}
+				if gap := times[i].Sub(times[i-1]); gap < want {
+					t.Errorf("retry %d ran %v after the previous attempt, want at least %v", i, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestJobQueueRetriesPanickingJobs(t *testing.T) {
+	var calls atomic.Int32
+	q, store := startJobQueue(t, testJobQueueConfig(), func(q *JobQueue) {
+		q.RegisterHandler("panics", func(ctx context.Context, job *models.Job) error {
+			if calls.Add(1) == 1 {
+				panic("boom")
+			}
+			return nil
+		}, 0)
+	})
+
+	job, err := q.Enqueue(context.Background(), "panics", nil)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	job = waitForJob(t, store, job.ID, JobSucceeded)
+	if job.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", job.Attempts)
+	}
+}
+
+func TestJobQueueLimitsConcurrencyPerType(t *testing.T) {
+	var running, peak atomic.Int32
+	q, store := startJobQueue(t, testJobQueueConfig(), func(q *JobQueue) {
+		q.RegisterHandler("serial", func(ctx context.Context, job *models.Job) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}, 1)
+	})
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		job, err := q.Enqueue(context.Background(), "serial", nil)
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		waitForJob(t, store, id, JobSucceeded)
+	}
+	if peak.Load() != 1 {
+		t.Errorf("%d jobs of a type limited to 1 ran at once", peak.Load())
+	}
+}
+
+func TestJobQueueRetryRequeuesDeadJobs(t *testing.T) {
+	store := NewMemoryJobStore()
+	q := NewJobQueue(store, testJobQueueConfig())
+	ctx := context.Background()
+
+	job, err := q.Enqueue(ctx, "manual", nil)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if _, err := q.Retry(ctx, job.ID); err == nil {
+		t.Error("Retry of a pending job succeeded, want an error")
+	}
+
+	job.Status, job.Attempts, job.LastError = JobDead, 3, "failed"
+	if err := store.Update(ctx, job); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	retried, err := q.Retry(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if retried.Status != JobPending || retried.Attempts != 0 {
+		t.Errorf("retried job is %s with %d attempts, want pending with 0", retried.Status, retried.Attempts)
+	}
+}
+
+func TestMemoryJobStoreLeases(t *testing.T) {
+	store := NewMemoryJobStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	job := &models.Job{Type: "leased", Status: JobPending, MaxAttempts: 3, RunAt: now}
+	if err := store.Create(ctx, job); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	claimed, err := store.ClaimDue(ctx, "leased", now, time.Minute, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue = %d jobs, %v; want 1", len(claimed), err)
+	}
+	if again, _ := store.ClaimDue(ctx, "leased", now, time.Minute, 10); len(again) != 0 {
+		t.Errorf("a running job was claimed twice")
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		want   int
+		status string
+	}{
+		{"within the lease", now.Add(30 * time.Second), 0, JobRunning},
+		{"after the lease", now.Add(2 * time.Minute), 1, JobPending},
+	}
+	for _, tt := range tests {
+		n, err := store.RequeueExpired(ctx, tt.at)
+		if err != nil {
+			t.Fatalf("%s: RequeueExpired: %v", tt.name, err)
+		}
+		job, _ := store.Get(ctx, job.ID)
+		if n != tt.want || job.Status != tt.status {
+			t.Errorf("%s: requeued %d, job is %s; want %d and %s", tt.name, n, job.Status, tt.want, tt.status)
+		}
+	}
+}
+
+func TestJobQueueLeaseOutlastsJobTimeout(t *testing.T) {
+	config := testJobQueueConfig()
+	release := make(chan struct{})
+	q, store := startJobQueue(t, config, func(q *JobQueue) {
+		q.RegisterHandler("slow", func(ctx context.Context, job *models.Job) error {
+			<-release
+			return nil
+		}, 0)
+	})
+
+	job, err := q.Enqueue(context.Background(), "slow", nil)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	running := waitForJob(t, store, job.ID, JobRunning)
+	close(release)
+
+	if lease := running.LeaseUntil.Sub(running.UpdatedAt); lease <= config.JobTimeout {
+		t.Errorf("lease of %v does not outlast the job timeout of %v", lease, config.JobTimeout)
+	}
+}
+
+func TestJobQueueBackoff(t *testing.T) {
+	q := NewJobQueue(NewMemoryJobStore(), JobQueueConfig{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := q.backoff(tt.attempts)
+			if got < tt.want || got > tt.want+tt.want/5 {
+				t.Errorf("backoff(%d) = %v, want %v plus at most 20%% jitter", tt.attempts, got, tt.want)
+				break
+			}
+		}
+	}
+}
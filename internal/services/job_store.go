@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"pawtroli-be/internal/models"
+)
+
+// Job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// ErrJobNotFound is returned when a job does not exist in the store
+var ErrJobNotFound = errors.New("job not found")
+
+// JobStore persists jobs for the JobQueue
+type JobStore interface {
+	// Create stores a new job and assigns its ID
+	Create(ctx context.Context, job *models.Job) error
+	// Get returns a single job
+	Get(ctx context.Context, id string) (*models.Job, error)
+	// Update overwrites an existing job
+	Update(ctx context.Context, job *models.Job) error
+	// List returns the most recently updated jobs, optionally filtered by status
+	List(ctx context.Context, status string, limit int) ([]*models.Job, error)
+	// ClaimDue atomically marks up to limit pending jobs of the given type that are due as running
+	ClaimDue(ctx context.Context, jobType string, now time.Time, lease time.Duration, limit int) ([]*models.Job, error)
+	// RequeueExpired marks running jobs whose lease expired as pending again
+	RequeueExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// MemoryJobStore is an in-memory JobStore for tests and local development
+type MemoryJobStore struct {
+	mu     sync.Mutex
+	jobs   map[string]*models.Job
+	nextID int
+}
+
+// NewMemoryJobStore creates a new in-memory job store
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]*models.Job)}
+}
+
+func (s *MemoryJobStore) Create(ctx context.Context, job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	job.ID = fmt.Sprintf("job-%d", s.nextID)
+	copied := *job
+	s.jobs[job.ID] = &copied
+	return nil
+}
+
+func (s *MemoryJobStore) Get(ctx context.Context, id string) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *MemoryJobStore) Update(ctx context.Context, job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.ID]; !ok {
+		return ErrJobNotFound
+	}
+	copied := *job
+	s.jobs[job.ID] = &copied
+	return nil
+}
+
+func (s *MemoryJobStore) List(ctx context.Context, status string, limit int) ([]*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*models.Job
+	for _, job := range s.jobs {
+		if status != "" && job.Status != status {
+			continue
+		}
+		copied := *job
+		result = append(result, &copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *MemoryJobStore) ClaimDue(ctx context.Context, jobType string, now time.Time, lease time.Duration, limit int) ([]*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*models.Job
+	for _, job := range s.jobs {
+		if job.Type == jobType && job.Status == JobPending && !job.RunAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].RunAt.Before(due[j].RunAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.Job, 0, len(due))
+	for _, job := range due {
+		job.Status = JobRunning
+		job.LeaseUntil = now.Add(lease)
+		job.UpdatedAt = now
+		copied := *job
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (s *MemoryJobStore) RequeueExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, job := range s.jobs {
+		if job.Status == JobRunning && job.LeaseUntil.Before(now) {
+			job.Status = JobPending
+			job.UpdatedAt = now
+			count++
+		}
+	}
+	return count, nil
+}
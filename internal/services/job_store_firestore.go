@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirestoreJobStore stores jobs in the "jobs" collection.
+// Claiming requires a composite index on (type, status, runAt).
+type FirestoreJobStore struct {
+	client *firestore.Client
+}
+
+// NewFirestoreJobStore creates a new Firestore backed job store
+func NewFirestoreJobStore(client *firestore.Client) *FirestoreJobStore {
+	return &FirestoreJobStore{client: client}
+}
+
+func (s *FirestoreJobStore) jobs() *firestore.CollectionRef {
+	return s.client.Collection("jobs")
+}
+
+func (s *FirestoreJobStore) Create(ctx context.Context, job *models.Job) error {
+	doc, _, err := s.jobs().Add(ctx, job)
+	if err != nil {
+		return err
+	}
+	job.ID = doc.ID
+	return nil
+}
+
+func (s *FirestoreJobStore) Get(ctx context.Context, id string) (*models.Job, error) {
+	doc, err := s.jobs().Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jobFromDoc(doc)
+}
+
+func (s *FirestoreJobStore) Update(ctx context.Context, job *models.Job) error {
+	_, err := s.jobs().Doc(job.ID).Set(ctx, job)
+	return err
+}
+
+func (s *FirestoreJobStore) List(ctx context.Context, jobStatus string, limit int) ([]*models.Job, error) {
+	q := s.jobs().Query
+	if jobStatus != "" {
+		q = q.Where("status", "==", jobStatus)
+	}
+	q = q.OrderBy("updatedAt", firestore.Desc)
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*models.Job, 0, len(docs))
+	for _, doc := range docs {
+		job, err := jobFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, job)
+	}
+	return result, nil
+}
+
+func (s *FirestoreJobStore) ClaimDue(ctx context.Context, jobType string, now time.Time, lease time.Duration, limit int) ([]*models.Job, error) {
+	q := s.jobs().
+		Where("type", "==", jobType).
+		Where("status", "==", JobPending).
+		Where("runAt", "<=", now).
+		OrderBy("runAt", firestore.Asc).
+		Limit(limit)
+
+	var claimed []*models.Job
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil // the transaction function may be retried
+		docs, err := tx.Documents(q).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			job, err := jobFromDoc(doc)
+			if err != nil {
+				return err
+			}
+			job.Status = JobRunning
+			job.LeaseUntil = now.Add(lease)
+			job.UpdatedAt = now
+			if err := tx.Set(doc.Ref, job); err != nil {
+				return err
+			}
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (s *FirestoreJobStore) RequeueExpired(ctx context.Context, now time.Time) (int, error) {
+	docs, err := s.jobs().
+		Where("status", "==", JobRunning).
+		Where("leaseUntil", "<", now).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, doc := range docs {
+		// Only requeue if nobody renewed or finished the job in the meantime
+		_, err := doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "status", Value: JobPending},
+			{Path: "updatedAt", Value: now},
+		}, firestore.LastUpdateTime(doc.UpdateTime))
+		if err != nil {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func jobFromDoc(doc *firestore.DocumentSnapshot) (*models.Job, error) {
+	job := new(models.Job)
+	if err := doc.DataTo(job); err != nil {
+		return nil, err
+	}
+	job.ID = doc.Ref.ID
+	return job, nil
+}
@@ -1,11 +1,15 @@
 package services
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"pawtroli-be/internal/logger"
@@ -16,40 +20,56 @@ type LogRotationService struct {
 	logDir         string
 	maxFiles       int
 	maxAge         time.Duration
+	maxTotalSize   int64
+	checkInterval  time.Duration
+	compressing    atomic.Bool
 	rotationTicker *time.Ticker
-	stopChan       chan bool
+	stopChan       chan struct{}
+	stopOnce       sync.Once
+	wg             sync.WaitGroup
 }
 
 // NewLogRotationService creates a new log rotation service
 func NewLogRotationService(logDir string, maxFiles int, maxAge time.Duration) *LogRotationService {
 	return &LogRotationService{
-		logDir:   logDir,
-		maxFiles: maxFiles,
-		maxAge:   maxAge,
-		stopChan: make(chan bool),
+		logDir:        logDir,
+		maxFiles:      maxFiles,
+		maxAge:        maxAge,
+		checkInterval: time.Minute,
+		stopChan:      make(chan struct{}),
 	}
 }
 
+// SetMaxTotalSize caps the combined size of the retained log files; the oldest files are
+// deleted first. A size of zero or less disables the cap.
+func (lrs *LogRotationService) SetMaxTotalSize(size int64) {
+	lrs.maxTotalSize = size
+}
+
 // Start begins the log rotation service
 func (lrs *LogRotationService) Start() {
 	logger.LogInfo("Starting log rotation service...")
 
-	// Run initial cleanup
-	lrs.cleanupOldLogs()
+	// Compress files left uncompressed by a previous run and clean up
+	lrs.compressRotatedLogs(logger.CurrentLogFile())
 
-	// Set up ticker to run every hour
-	lrs.rotationTicker = time.NewTicker(1 * time.Hour)
+	// Check often enough to keep files close to the maximum size
+	lrs.rotationTicker = time.NewTicker(lrs.checkInterval)
 
+	lrs.wg.Add(1)
 	go func() {
+		defer lrs.wg.Done()
 		for {
 			select {
 			case <-lrs.rotationTicker.C:
 				// Check if we need to rotate the current log file
-				if err := logger.RotateLogFile(); err != nil {
+				rotated, err := logger.RotateLogFile()
+				if err != nil {
 					logger.LogErrorf("Failed to rotate log file: %v", err)
 				}
-				// Clean up old log files
-				lrs.cleanupOldLogs()
+				if rotated {
+					lrs.compressRotatedLogs(logger.CurrentLogFile())
+				}
 			case <-lrs.stopChan:
 				lrs.rotationTicker.Stop()
 				return
@@ -58,44 +78,123 @@ func (lrs *LogRotationService) Start() {
 	}()
 }
 
-// Stop stops the log rotation service
+// Stop stops the log rotation service and waits for a running compression to finish.
+// It is safe to call more than once.
 func (lrs *LogRotationService) Stop() {
-	logger.LogInfo("Stopping log rotation service...")
-	if lrs.rotationTicker != nil {
-		lrs.stopChan <- true
+	lrs.stopOnce.Do(func() {
+		logger.LogInfo("Stopping log rotation service...")
+		close(lrs.stopChan)
+		lrs.wg.Wait()
+	})
+}
+
+// compressRotatedLogs gzips every uncompressed log file except the current one in the
+// background, then cleans up old files. Runs are skipped while one is in progress.
+func (lrs *LogRotationService) compressRotatedLogs(current string) {
+	if !lrs.compressing.CompareAndSwap(false, true) {
+		return
 	}
+	lrs.wg.Add(1)
+	go func() {
+		defer lrs.wg.Done()
+		defer lrs.compressing.Store(false)
+
+		files, err := lrs.getLogFiles()
+		if err != nil {
+			logger.LogErrorf("Failed to get log files for compression: %v", err)
+			return
+		}
+		for _, file := range files {
+			if file.Name() == current || !strings.HasSuffix(file.Name(), ".log") {
+				continue
+			}
+			if err := compressLogFile(filepath.Join(lrs.logDir, file.Name())); err != nil {
+				logger.LogErrorf("Failed to compress log file %s: %v", file.Name(), err)
+				continue
+			}
+			logger.LogInfof("Compressed log file: %s", file.Name())
+		}
+		lrs.cleanupOldLogs(current)
+	}()
 }
 
-// cleanupOldLogs removes old log files based on age and count limits
-func (lrs *LogRotationService) cleanupOldLogs() {
+// compressLogFile replaces a log file with a gzip archive that keeps its modification time
+func compressLogFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary name first so a partial archive is never listed
+	tmpPath := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = info.Name()
+	zw.ModTime = info.ModTime()
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// cleanupOldLogs removes old log files based on age, count and total size limits.
+// The current log file is never removed.
+func (lrs *LogRotationService) cleanupOldLogs(current string) {
 	files, err := lrs.getLogFiles()
 	if err != nil {
 		logger.LogErrorf("Failed to get log files for cleanup: %v", err)
 		return
 	}
 
-	now := time.Now()
-	var filesToDelete []string
+	// Sort files by modification time (oldest first)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
 
-	// Check age-based cleanup
+	now := time.Now()
+	var totalSize int64
 	for _, file := range files {
-		if now.Sub(file.ModTime()) > lrs.maxAge {
-			filesToDelete = append(filesToDelete, file.Name())
-		}
+		totalSize += file.Size()
 	}
+	remaining := len(files)
 
-	// Check count-based cleanup
-	if len(files) > lrs.maxFiles {
-		// Sort files by modification time (oldest first)
-		sort.Slice(files, func(i, j int) bool {
-			return files[i].ModTime().Before(files[j].ModTime())
-		})
-
-		// Mark excess files for deletion
-		excessCount := len(files) - lrs.maxFiles
-		for i := 0; i < excessCount; i++ {
-			filesToDelete = append(filesToDelete, files[i].Name())
+	var filesToDelete []string
+	for _, file := range files {
+		if file.Name() == current {
+			continue
+		}
+		expired := now.Sub(file.ModTime()) > lrs.maxAge
+		excess := remaining > lrs.maxFiles
+		oversize := lrs.maxTotalSize > 0 && totalSize > lrs.maxTotalSize
+		if !expired && !excess && !oversize {
+			continue
 		}
+		filesToDelete = append(filesToDelete, file.Name())
+		remaining--
+		totalSize -= file.Size()
 	}
 
 	// Delete marked files
@@ -118,7 +217,8 @@ func (lrs *LogRotationService) getLogFiles() ([]os.FileInfo, error) {
 
 	var logFiles []os.FileInfo
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "pawtroli_") && strings.HasSuffix(entry.Name(), ".log") {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, "pawtroli_") && (strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
 			info, err := entry.Info()
 			if err != nil {
 				continue
@@ -130,28 +230,36 @@ func (lrs *LogRotationService) getLogFiles() ([]os.FileInfo, error) {
 	return logFiles, nil
 }
 
+// LogFileInfo describes a retained log file
+type LogFileInfo struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"modTime"`
+	SizeFormatted string    `json:"sizeFormatted"`
+	Compressed    bool      `json:"compressed"`
+}
+
 // GetLogFilesList returns a list of available log files with their sizes
-func (lrs *LogRotationService) GetLogFilesList() ([]map[string]interface{}, error) {
+func (lrs *LogRotationService) GetLogFilesList() ([]LogFileInfo, error) {
 	files, err := lrs.getLogFiles()
 	if err != nil {
 		return nil, err
 	}
 
-	var result []map[string]interface{}
+	result := make([]LogFileInfo, 0, len(files))
 	for _, file := range files {
-		result = append(result, map[string]interface{}{
-			"name":          file.Name(),
-			"size":          file.Size(),
-			"modTime":       file.ModTime(),
-			"sizeFormatted": formatFileSize(file.Size()),
+		result = append(result, LogFileInfo{
+			Name:          file.Name(),
+			Size:          file.Size(),
+			ModTime:       file.ModTime(),
+			SizeFormatted: formatFileSize(file.Size()),
+			Compressed:    strings.HasSuffix(file.Name(), ".gz"),
 		})
 	}
 
 	// Sort by modification time (newest first)
 	sort.Slice(result, func(i, j int) bool {
-		timeI := result[i]["modTime"].(time.Time)
-		timeJ := result[j]["modTime"].(time.Time)
-		return timeI.After(timeJ)
+		return result[i].ModTime.After(result[j].ModTime)
 	})
 
 	return result, nil
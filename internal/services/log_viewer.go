@@ -0,0 +1,315 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"pawtroli-be/internal/logger"
+)
+
+// ErrLogFileNotFound is returned for names that are not a retained log file
+var ErrLogFileNotFound = errors.New("log file not found")
+
+// ErrLogFileCompressed is returned when following a compressed log file
+var ErrLogFileCompressed = errors.New("log file is compressed")
+
+// ErrLogRotated is returned when a followed log file is no longer the file being written to
+var ErrLogRotated = errors.New("log file rotated")
+
+// LogLine is a parsed line of a log file. Lines that do not start with a level prefix
+// (e.g. continuation lines of multi-line messages) only have Number and Raw set.
+type LogLine struct {
+	Number  int       `json:"number"`
+	Level   string    `json:"level,omitempty"`
+	Time    time.Time `json:"time,omitzero"`
+	Source  string    `json:"source,omitempty"`
+	Message string    `json:"message"`
+	Raw     string    `json:"raw"`
+}
+
+// LogSearchQuery filters log lines; empty fields match everything
+type LogSearchQuery struct {
+	Level     string // DEBUG, INFO, WARNING or ERROR
+	From      time.Time
+	To        time.Time
+	Contains  string // case insensitive substring
+	RequestID string
+	Limit     int
+}
+
+// LogSearchResult is a log line matching a search, with the file it was found in
+type LogSearchResult struct {
+	File string `json:"file"`
+	LogLine
+}
+
+// logLinePattern matches lines written by the logger package:
+// "[INFO] 2025/07/22 14:00:00 file.go:42: message"
+var logLinePattern = regexp.MustCompile(`^\[(DEBUG|INFO|WARNING|ERROR)\] (\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) ([^:\s]+:\d+): ?(.*)$`)
+
+// ParseLogLine parses a raw log line
+func ParseLogLine(number int, raw string) LogLine {
+	line := LogLine{Number: number, Message: raw, Raw: raw}
+	m := logLinePattern.FindStringSubmatch(raw)
+	if m == nil {
+		return line
+	}
+	line.Level = m[1]
+	line.Time, _ = time.ParseInLocation("2006/01/02 15:04:05", m[2], time.Local)
+	line.Source = m[3]
+	line.Message = m[4]
+	return line
+}
+
+// ResolveLogFile returns the path of a retained log file. Only bare file names of files
+// listed by the service are accepted, so paths like "../config.json" are rejected.
+func (lrs *LogRotationService) ResolveLogFile(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return "", ErrLogFileNotFound
+	}
+	files, err := lrs.getLogFiles()
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if file.Name() == name {
+			return filepath.Join(lrs.logDir, name), nil
+		}
+	}
+	return "", ErrLogFileNotFound
+}
+
+// ReadLines returns up to limit lines of a log file starting at line from (1-based),
+// and whether the file has more lines after them
+func (lrs *LogRotationService) ReadLines(name string, from, limit int) ([]LogLine, bool, error) {
+	path, err := lrs.ResolveLogFile(name)
+	if err != nil {
+		return nil, false, err
+	}
+	f, err := openLogReader(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var lines []LogLine
+	scanner := newLogScanner(f)
+	number := 0
+	for scanner.Scan() {
+		number++
+		if number < from {
+			continue
+		}
+		if len(lines) == limit {
+			return lines, true, nil
+		}
+		lines = append(lines, ParseLogLine(number, scanner.Text()))
+	}
+	return lines, false, scanner.Err()
+}
+
+// LastLines returns the last n lines of a log file and the file offset after them, for
+// following the file with TailLogFile. Compressed files are rejected.
+func (lrs *LogRotationService) LastLines(name string, n int) ([]LogLine, int64, error) {
+	path, err := lrs.ResolveLogFile(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		return nil, 0, ErrLogFileCompressed
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var lines []LogLine
+	scanner := newLogScanner(f)
+	number := 0
+	for scanner.Scan() {
+		number++
+		lines = append(lines, ParseLogLine(number, scanner.Text()))
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	return lines, offset, err
+}
+
+// TailLogFile follows a log file from offset, calling fn for every complete line appended
+// until ctx is done. If the file is truncated it is read again from the start. Once the
+// logger has moved on to another file the rest of this one is read and ErrLogRotated returned.
+func (lrs *LogRotationService) TailLogFile(ctx context.Context, name string, offset int64, fn func(LogLine) error) error {
+	path, err := lrs.ResolveLogFile(name)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		return ErrLogFileCompressed
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	var pending string
+	buf := make([]byte, 32*1024)
+	for {
+		// Checked before reading: nothing is written to a file after the logger has left it
+		current := logger.CurrentLogFile()
+		rotated := current != "" && current != name
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() < offset {
+			offset, pending = 0, ""
+		}
+		for info.Size() > offset {
+			n, err := f.ReadAt(buf, offset)
+			offset += int64(n)
+			pending += string(buf[:n])
+			for {
+				i := strings.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				if err := fn(ParseLogLine(0, strings.TrimRight(pending[:i], "\r"))); err != nil {
+					return err
+				}
+				pending = pending[i+1:]
+			}
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+		}
+		if rotated {
+			return ErrLogRotated
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// SearchLogs searches the retained log files, newest file first, and returns up to
+// query.Limit matching lines
+func (lrs *LogRotationService) SearchLogs(ctx context.Context, query LogSearchQuery) ([]LogSearchResult, error) {
+	files, err := lrs.getLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+
+	contains := strings.ToLower(query.Contains)
+	results := []LogSearchResult{}
+	for _, file := range files {
+		// A file cannot contain lines newer than its last modification
+		if !query.From.IsZero() && file.ModTime().Before(query.From) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		f, err := openLogReader(filepath.Join(lrs.logDir, file.Name()))
+		if err != nil {
+			return results, err
+		}
+		scanner := newLogScanner(f)
+		number := 0
+		for scanner.Scan() {
+			number++
+			raw := scanner.Text()
+			if contains != "" && !strings.Contains(strings.ToLower(raw), contains) {
+				continue
+			}
+			if query.RequestID != "" && !strings.Contains(raw, query.RequestID) {
+				continue
+			}
+			line := ParseLogLine(number, raw)
+			if query.Level != "" && line.Level != query.Level {
+				continue
+			}
+			if (!query.From.IsZero() || !query.To.IsZero()) && line.Time.IsZero() {
+				continue
+			}
+			if !query.From.IsZero() && line.Time.Before(query.From) {
+				continue
+			}
+			if !query.To.IsZero() && !line.Time.Before(query.To) {
+				continue
+			}
+			results = append(results, LogSearchResult{File: file.Name(), LogLine: line})
+			if query.Limit > 0 && len(results) >= query.Limit {
+				f.Close()
+				return results, nil
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// gzipFile closes both the gzip reader and the underlying file
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// openLogReader opens a log file for reading, decompressing .log.gz archives
+func openLogReader(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: zr, file: f}, nil
+}
+
+// newLogScanner returns a line scanner that tolerates long log lines
+func newLogScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"pawtroli-be/internal/logger"
+
+	gcs "cloud.google.com/go/storage"
+	firebase "firebase.google.com/go/v4"
+)
+
+// MediaDeleter removes uploaded media referenced by URL. Media URLs are set by clients, so
+// only objects under the given prefixes, which belong to the resource being cleaned up,
+// are ever deleted.
+type MediaDeleter interface {
+	Delete(ctx context.Context, mediaURL string, prefixes ...string) error
+	// Allows reports whether a resource whose media is stored under the prefixes may
+	// reference mediaURL, so that clients cannot point it at someone else's files
+	Allows(mediaURL string, prefixes ...string) bool
+}
+
+// UserMediaPrefix is the storage folder of the files uploaded by a user, e.g. their profile photo
+func UserMediaPrefix(uid string) string {
+	return "users/" + uid + "/"
+}
+
+// PetMediaPrefix is the storage folder of the images of a pet and its updates
+func PetMediaPrefix(petID string) string {
+	return "pets/" + petID + "/"
+}
+
+// StorageMediaDeleter deletes objects from a Firebase Storage bucket. URLs pointing
+// elsewhere are ignored, so externally hosted images are never touched.
+type StorageMediaDeleter struct {
+	bucket     *gcs.BucketHandle
+	bucketName string
+}
+
+// NewStorageMediaDeleter creates a media deleter for the given Firebase Storage bucket
+func NewStorageMediaDeleter(ctx context.Context, app *firebase.App, bucketName string) (*StorageMediaDeleter, error) {
+	client, err := app.Storage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %v", err)
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bucket %s: %v", bucketName, err)
+	}
+	return &StorageMediaDeleter{bucket: bucket, bucketName: bucketName}, nil
+}
+
+func (d *StorageMediaDeleter) Delete(ctx context.Context, mediaURL string, prefixes ...string) error {
+	object, ok := storageObjectPath(mediaURL, d.bucketName)
+	if !ok {
+		logger.LogDebugf("Skipping media outside bucket %s: %s", d.bucketName, mediaURL)
+		return nil
+	}
+	if !hasAnyPrefix(object, prefixes) {
+		logger.LogWarningf("Not deleting %s, which is outside %v", object, prefixes)
+		return nil
+	}
+	err := d.bucket.Object(object).Delete(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Allows accepts URLs outside the bucket, which are never deleted, and objects under the prefixes
+func (d *StorageMediaDeleter) Allows(mediaURL string, prefixes ...string) bool {
+	object, ok := storageObjectPath(mediaURL, d.bucketName)
+	return !ok || hasAnyPrefix(object, prefixes)
+}
+
+// storageObjectPath extracts the object path from a Firebase Storage download URL
+// (https://firebasestorage.googleapis.com/v0/b/{bucket}/o/{object}) or a public
+// Cloud Storage URL (https://storage.googleapis.com/{bucket}/{object})
+func storageObjectPath(mediaURL, bucketName string) (string, bool) {
+	u, err := url.Parse(mediaURL)
+	if err != nil {
+		return "", false
+	}
+	switch u.Host {
+	case "firebasestorage.googleapis.com":
+		prefix := "/v0/b/" + bucketName + "/o/"
+		if !strings.HasPrefix(u.Path, prefix) {
+			return "", false
+		}
+		return strings.TrimPrefix(u.Path, prefix), true
+	case "storage.googleapis.com":
+		prefix := "/" + bucketName + "/"
+		if !strings.HasPrefix(u.Path, prefix) {
+			return "", false
+		}
+		return strings.TrimPrefix(u.Path, prefix), true
+	default:
+		return "", false
+	}
+}
+
+func hasAnyPrefix(object string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(object, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NoopMediaDeleter is used when no storage bucket is configured
+type NoopMediaDeleter struct{}
+
+func (NoopMediaDeleter) Delete(ctx context.Context, mediaURL string, prefixes ...string) error {
+	return nil
+}
+
+func (NoopMediaDeleter) Allows(mediaURL string, prefixes ...string) bool {
+	return true
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NotificationType identifies the event that triggered a notification
+type NotificationType string
+
+const (
+	NotificationPetUpdate    NotificationType = "pet_update"
+	NotificationChatMessage  NotificationType = "chat_message"
+	NotificationPetActivated NotificationType = "pet_activated"
+)
+
+// Notification is a provider independent push notification
+type Notification struct {
+	Type  NotificationType  `json:"type"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// SendResult lists the tokens a notification was delivered to and the tokens the provider
+// rejected as invalid or unregistered. Tokens in neither list failed and may be retried.
+type SendResult struct {
+	Delivered []string
+	Invalid   []string
+}
+
+// NotificationSender delivers a notification to a set of device tokens. It returns an error
+// if some tokens failed, along with the result for the tokens handled so far.
+type NotificationSender interface {
+	Send(ctx context.Context, tokens []string, n Notification) (SendResult, error)
+}
+
+// NotificationService manages device tokens and preferences and sends push notifications
+type NotificationService struct {
+	client *firestore.Client
+	sender NotificationSender
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(client *firestore.Client, sender NotificationSender) *NotificationService {
+	return &NotificationService{
+		client: client,
+		sender: sender,
+	}
+}
+
+// DefaultNotificationPreferences returns the preferences used when a user has not saved any
+func DefaultNotificationPreferences() models.NotificationPreferences {
+	return models.NotificationPreferences{
+		PetUpdates:    true,
+		ChatMessages:  true,
+		PetActivation: true,
+	}
+}
+
+func (ns *NotificationService) devices(uid string) *firestore.CollectionRef {
+	return ns.client.Collection("users").Doc(uid).Collection("devices")
+}
+
+// deviceOwner records which user a device token is registered to, keyed by the token
+func (ns *NotificationService) deviceOwner(token string) *firestore.DocumentRef {
+	return ns.client.Collection("device_owners").Doc(token)
+}
+
+// RegisterDevice stores a device token for the given user. A device signed into another
+// account before is removed from that account, so its pushes no longer reach the device.
+func (ns *NotificationService) RegisterDevice(ctx context.Context, uid string, device models.DeviceToken) error {
+	device.CreatedAt = time.Now()
+	owner := ns.deviceOwner(device.Token)
+	return ns.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(owner)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			previous, _ := doc.DataAt("uid")
+			if previous, ok := previous.(string); ok && previous != "" && previous != uid {
+				if err := tx.Delete(ns.devices(previous).Doc(device.Token)); err != nil {
+					return err
+				}
+				logger.LogInfof("Moving device from user %s to user %s", previous, uid)
+			}
+		}
+		if err := tx.Set(ns.devices(uid).Doc(device.Token), device); err != nil {
+			return err
+		}
+		return tx.Set(owner, map[string]interface{}{"uid": uid, "updatedAt": device.CreatedAt})
+	})
+}
+
+// UnregisterDevice removes a device token from the given user
+func (ns *NotificationService) UnregisterDevice(ctx context.Context, uid, token string) error {
+	owner := ns.deviceOwner(token)
+	return ns.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(owner)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if current, _ := doc.DataAt("uid"); current == uid {
+				if err := tx.Delete(owner); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Delete(ns.devices(uid).Doc(token))
+	})
+}
+
+// GetDeviceTokens returns all registered device tokens of the given user
+func (ns *NotificationService) GetDeviceTokens(ctx context.Context, uid string) ([]string, error) {
+	docs, err := ns.devices(uid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		tokens = append(tokens, doc.Ref.ID)
+	}
+	return tokens, nil
+}
+
+// GetPreferences returns the notification preferences of the given user
+func (ns *NotificationService) GetPreferences(ctx context.Context, uid string) (models.NotificationPreferences, error) {
+	prefs := DefaultNotificationPreferences()
+
+	doc, err := ns.client.Collection("notification_preferences").Doc(uid).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, err
+	}
+	if err := doc.DataTo(&prefs); err != nil {
+		return prefs, err
+	}
+	return prefs, nil
+}
+
+// SetPreferences saves the notification preferences of the given user
+func (ns *NotificationService) SetPreferences(ctx context.Context, uid string, prefs models.NotificationPreferences) error {
+	_, err := ns.client.Collection("notification_preferences").Doc(uid).Set(ctx, prefs)
+	return err
+}
+
+// DeliveryKey identifies a device token in the delivered list of a notification without
+// storing the token itself
+func DeliveryKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NotifyUser sends a notification to every device of the given user, honoring their preferences.
+// Devices whose DeliveryKey is in delivered, which lists the deliveries of an earlier attempt,
+// are skipped. It returns delivered with the devices reached now, also when others failed, so
+// that a retry only sends to the devices that failed.
+func (ns *NotificationService) NotifyUser(ctx context.Context, uid string, n Notification, delivered []string) ([]string, error) {
+	prefs, err := ns.GetPreferences(ctx, uid)
+	if err != nil {
+		return delivered, fmt.Errorf("failed to get notification preferences: %v", err)
+	}
+	if !wantsNotification(prefs, n.Type) {
+		logger.LogDebugf("Notification %s disabled by user %s", n.Type, uid)
+		return delivered, nil
+	}
+
+	tokens, err := ns.GetDeviceTokens(ctx, uid)
+	if err != nil {
+		return delivered, fmt.Errorf("failed to get device tokens: %v", err)
+	}
+	tokens = slices.DeleteFunc(tokens, func(token string) bool {
+		return slices.Contains(delivered, DeliveryKey(token))
+	})
+	if len(tokens) == 0 {
+		logger.LogDebugf("No devices left to notify for user %s", uid)
+		return delivered, nil
+	}
+
+	result, err := ns.sender.Send(ctx, tokens, n)
+	for _, token := range result.Delivered {
+		delivered = append(delivered, DeliveryKey(token))
+	}
+
+	// Clean up tokens the provider rejected, even if other tokens failed
+	for _, token := range result.Invalid {
+		if err := ns.UnregisterDevice(ctx, uid, token); err != nil {
+			logger.LogErrorf("Failed to remove invalid device token for user %s: %v", uid, err)
+			continue
+		}
+		logger.LogInfof("Removed invalid device token for user %s", uid)
+	}
+
+	if err != nil {
+		return delivered, fmt.Errorf("failed to send notification: %v", err)
+	}
+	logger.LogInfof("Notification %s sent to %d device(s) of user %s", n.Type, len(result.Delivered), uid)
+	return delivered, nil
+}
+
+// NotifyPetOwner sends a notification to the owner of the given pet, skipping the devices in
+// delivered like NotifyUser
+func (ns *NotificationService) NotifyPetOwner(ctx context.Context, petID string, n Notification, delivered []string) ([]string, error) {
+	doc, err := ns.client.Collection("pets").Doc(petID).Get(ctx)
+	if err != nil {
+		return delivered, fmt.Errorf("failed to get pet %s: %v", petID, err)
+	}
+
+	var pet models.Pet
+	if err := doc.DataTo(&pet); err != nil {
+		return delivered, fmt.Errorf("failed to decode pet %s: %v", petID, err)
+	}
+	if pet.OwnerID == "" {
+		logger.LogWarningf("Pet %s has no owner, skipping notification", petID)
+		return delivered, nil
+	}
+	return ns.NotifyUser(ctx, pet.OwnerID, n, delivered)
+}
+
+// NotifyChatParticipants sends a notification to every participant of a chat room except the
+// sender, skipping the devices in delivered like NotifyUser. It returns delivered with the
+// devices reached now, also when others failed, so that a retry does not notify any device twice.
+func (ns *NotificationService) NotifyChatParticipants(ctx context.Context, roomID, senderID string, n Notification, delivered []string) ([]string, error) {
+	doc, err := ns.client.Collection("chats").Doc(roomID).Get(ctx)
+	if err != nil {
+		return delivered, fmt.Errorf("failed to get chat room %s: %v", roomID, err)
+	}
+
+	var room models.ChatRoom
+	if err := doc.DataTo(&room); err != nil {
+		return delivered, fmt.Errorf("failed to decode chat room %s: %v", roomID, err)
+	}
+
+	var lastErr error
+	for _, uid := range room.UserIDs {
+		if uid == senderID {
+			continue
+		}
+		delivered, err = ns.NotifyUser(ctx, uid, n, delivered)
+		if err != nil {
+			logger.LogErrorf("Failed to notify user %s in room %s: %v", uid, roomID, err)
+			lastErr = err
+		}
+	}
+	return delivered, lastErr
+}
+
+// wantsNotification reports whether the preferences allow the given notification type
+func wantsNotification(prefs models.NotificationPreferences, t NotificationType) bool {
+	switch t {
+	case NotificationPetUpdate:
+		return prefs.PetUpdates
+	case NotificationChatMessage:
+		return prefs.ChatMessages
+	case NotificationPetActivated:
+		return prefs.PetActivation
+	default:
+		return true
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"pawtroli-be/internal/logger"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+)
+
+// fcmMaxTokensPerBatch is the maximum number of tokens FCM accepts in a single multicast
+const fcmMaxTokensPerBatch = 500
+
+// FCMSender sends notifications through Firebase Cloud Messaging
+type FCMSender struct {
+	client *messaging.Client
+}
+
+// NewFCMSender creates a new FCM sender from the Firebase app
+func NewFCMSender(ctx context.Context, app *firebase.App) (*FCMSender, error) {
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messaging client: %v", err)
+	}
+	return &FCMSender{client: client}, nil
+}
+
+// Send delivers the notification to all tokens, batching as needed
+func (s *FCMSender) Send(ctx context.Context, tokens []string, n Notification) (SendResult, error) {
+	data := make(map[string]string, len(n.Data)+1)
+	for k, v := range n.Data {
+		data[k] = v
+	}
+	data["type"] = string(n.Type)
+
+	var result SendResult
+	var failed int
+	for start := 0; start < len(tokens); start += fcmMaxTokensPerBatch {
+		end := start + fcmMaxTokensPerBatch
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		batch := tokens[start:end]
+
+		resp, err := s.client.SendEachForMulticast(ctx, &messaging.MulticastMessage{
+			Tokens: batch,
+			Data:   data,
+			Notification: &messaging.Notification{
+				Title: n.Title,
+				Body:  n.Body,
+			},
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for i, r := range resp.Responses {
+			if r.Success {
+				result.Delivered = append(result.Delivered, batch[i])
+				continue
+			}
+			if isInvalidToken(r.Error) {
+				result.Invalid = append(result.Invalid, batch[i])
+				continue
+			}
+			logger.LogWarningf("FCM send failed: %v", r.Error)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return result, fmt.Errorf("%d of %d messages failed", failed, len(tokens))
+	}
+	return result, nil
+}
+
+// isInvalidToken reports whether FCM rejected the token itself. INVALID_ARGUMENT is also
+// returned for payload problems such as oversized data, so it only counts when the message
+// blames the registration token.
+func isInvalidToken(err error) bool {
+	if messaging.IsUnregistered(err) {
+		return true
+	}
+	return messaging.IsInvalidArgument(err) && strings.Contains(err.Error(), "not a valid FCM registration token")
+}
+
+// LogSender is the NotificationSender used when no push provider is available. It logs and
+// drops every notification, keeping nothing in memory.
+type LogSender struct{}
+
+// Send logs the notification and reports it as delivered
+func (LogSender) Send(ctx context.Context, tokens []string, n Notification) (SendResult, error) {
+	logger.LogDebugf("LogSender: %s notification %q dropped for %d device(s)", n.Type, n.Title, len(tokens))
+	return SendResult{Delivered: tokens}, nil
+}
+
+// SentNotification records a notification delivered by the FakeSender
+type SentNotification struct {
+	Token        string
+	Notification Notification
+}
+
+// FakeSender is an in-memory NotificationSender for tests. It keeps every notification it
+// sends, so long running processes should use LogSender instead.
+type FakeSender struct {
+	mu      sync.Mutex
+	sent    []SentNotification
+	invalid map[string]bool
+}
+
+// NewFakeSender creates a new in-memory sender
+func NewFakeSender() *FakeSender {
+	return &FakeSender{invalid: make(map[string]bool)}
+}
+
+// MarkInvalid makes subsequent sends report the given tokens as invalid
+func (s *FakeSender) MarkInvalid(tokens ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range tokens {
+		s.invalid[token] = true
+	}
+}
+
+// Send records the notification for every valid token
+func (s *FakeSender) Send(ctx context.Context, tokens []string, n Notification) (SendResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result SendResult
+	for _, token := range tokens {
+		if s.invalid[token] {
+			result.Invalid = append(result.Invalid, token)
+			continue
+		}
+		s.sent = append(s.sent, SentNotification{Token: token, Notification: n})
+		result.Delivered = append(result.Delivered, token)
+		logger.LogDebugf("FakeSender: %s notification %q sent", n.Type, n.Title)
+	}
+	return result, nil
+}
+
+// Sent returns a copy of all notifications recorded so far
+func (s *FakeSender) Sent() []SentNotification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentNotification(nil), s.sent...)
+}
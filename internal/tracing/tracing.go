@@ -0,0 +1,79 @@
+// Package tracing sets up OpenTelemetry tracing with an OTLP/HTTP exporter.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"pawtroli-be/internal/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Config configures tracing
+type Config struct {
+	ServiceName string
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318. Spans are
+	// not exported when it is empty.
+	Endpoint string
+	// SampleRatio is the fraction of new traces that are sampled; requests that are part
+	// of a sampled trace are always sampled
+	SampleRatio float64
+}
+
+// ConfigFromEnv reads the configuration from the standard OpenTelemetry variables
+// OTEL_SERVICE_NAME, OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_TRACES_SAMPLER_ARG
+func ConfigFromEnv() Config {
+	cfg := Config{
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+		Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		SampleRatio: 1,
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "pawtroli-be"
+	}
+	if arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); arg != "" {
+		if ratio, err := strconv.ParseFloat(arg, 64); err == nil && ratio >= 0 && ratio <= 1 {
+			cfg.SampleRatio = ratio
+		} else {
+			logger.LogWarningf("Ignoring invalid OTEL_TRACES_SAMPLER_ARG %q", arg)
+		}
+	}
+	return cfg
+}
+
+// Init installs the global tracer provider and W3C trace context propagation. The returned
+// function flushes pending spans and must be called on shutdown.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Endpoint == "" {
+		logger.LogInfo("OTEL_EXPORTER_OTLP_ENDPOINT not set, traces are not exported")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	logger.LogInfof("Exporting traces of %s to %s (sample ratio %g)", cfg.ServiceName, cfg.Endpoint, cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"pawtroli-be/internal/apperror"
+)
+
+// DefaultMaxBodyBytes is the largest JSON body DecodeJSON accepts
+const DefaultMaxBodyBytes = 1 << 20 // 1 MB
+
+// DecodeJSON strictly decodes a single JSON object from the request body into v and validates it.
+// Unknown fields, trailing data and bodies over DefaultMaxBodyBytes are rejected. The returned
+// error is an *apperror.Error ready to be written with apperror.Write.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return DecodeJSONWithLimit(w, r, v, DefaultMaxBodyBytes)
+}
+
+// DecodeJSONWithLimit is DecodeJSON with a custom body size limit
+func DecodeJSONWithLimit(w http.ResponseWriter, r *http.Request, v interface{}, maxBytes int64) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(strings.ToLower(ct), "application/json") {
+		return apperror.Validation("Content-Type must be application/json")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err, maxBytes)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err, maxBytes)
+		}
+		return apperror.Validation("Request body must contain a single JSON object")
+	}
+
+	if fields := Struct(v); len(fields) > 0 {
+		return apperror.Validation("Validation failed", fields...)
+	}
+	return nil
+}
+
+// decodeError converts a JSON decoding error into a validation error with field details
+func decodeError(err error, maxBytes int64) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return apperror.Validation("Request body must not be empty")
+	case errors.As(err, &maxErr):
+		return apperror.TooLarge(fmt.Sprintf("Request body must not be larger than %d bytes", maxBytes))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apperror.Validation("Malformed JSON body")
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return apperror.Validation("Request body must be a JSON object")
+		}
+		return apperror.Validation("Validation failed", apperror.FieldError{
+			Field:   field,
+			Message: "must be of type " + typeErr.Type.String(),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apperror.Validation("Validation failed", apperror.FieldError{
+			Field:   field,
+			Message: "is not allowed",
+		})
+	default:
+		return apperror.Validation("Invalid body")
+	}
+}
@@ -0,0 +1,250 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pawtroli-be/internal/apperror"
+)
+
+// Optional is implemented by wrapper types, such as merge-patch fields, whose value may be absent
+type Optional interface {
+	OptionalValue() (interface{}, bool)
+}
+
+// Struct validates v against the `validate` struct tags of its fields and returns one error per
+// invalid field. Fields are reported by their JSON name.
+//
+// Supported rules, separated by commas:
+//
+//	required     value must not be the zero value (strings must not be blank)
+//	min=N        minimum number, or minimum length of a string or slice
+//	max=N        maximum number, or maximum length of a string or slice
+//	oneof=a b c  value must be one of the space separated options
+//	email        valid email address
+//	e164         phone number in E.164 format, e.g. +6281234567890
+//	url          absolute http or https URL
+//	rfc3339      timestamp in RFC 3339 format
+//	docid        usable as a Firestore document ID
+//	dive         validate every element of a slice with the rules that follow, and the
+//	             fields of struct elements with their own tags
+//
+// Rules other than required are skipped for empty strings, so optional fields only need
+// to be valid when present. Fields implementing Optional are only validated when present.
+func Struct(v interface{}) []apperror.FieldError {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	return validateStruct(rv, "")
+}
+
+func validateStruct(rv reflect.Value, prefix string) []apperror.FieldError {
+	var errs []apperror.FieldError
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + jsonName(field)
+		value := rv.Field(i)
+
+		if opt, ok := value.Interface().(Optional); ok {
+			inner, present := opt.OptionalValue()
+			if !present {
+				continue
+			}
+			value = reflect.ValueOf(inner)
+		}
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			if msg := validateValue(value, strings.Split(tag, ",")); msg != "" {
+				errs = append(errs, apperror.FieldError{Field: name, Message: msg})
+				continue
+			}
+			if idx := indexOf(strings.Split(tag, ","), "dive"); idx >= 0 && value.Kind() == reflect.Slice {
+				rules := strings.Split(tag, ",")[idx+1:]
+				for j := 0; j < value.Len(); j++ {
+					elem := fmt.Sprintf("%s[%d]", name, j)
+					if msg := validateValue(value.Index(j), rules); msg != "" {
+						errs = append(errs, apperror.FieldError{Field: elem, Message: msg})
+						continue
+					}
+					if value.Index(j).Kind() == reflect.Struct {
+						errs = append(errs, validateStruct(value.Index(j), elem+".")...)
+					}
+				}
+			}
+		}
+
+		// Recurse into nested structs (but not time.Time)
+		nested := value
+		if nested.Kind() == reflect.Ptr && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && nested.Type() != reflect.TypeOf(time.Time{}) {
+			errs = append(errs, validateStruct(nested, name+".")...)
+		}
+	}
+	return errs
+}
+
+// validateValue applies the rules to a single value and returns the first failure message
+func validateValue(value reflect.Value, rules []string) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if indexOf(rules, "required") >= 0 {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "dive" {
+			break // the remaining rules apply to the elements
+		}
+		if name == "" || name == "omitempty" {
+			continue
+		}
+
+		if name == "required" {
+			if isBlank(value) {
+				return "is required"
+			}
+			continue
+		}
+		if value.Kind() == reflect.String && value.String() == "" {
+			continue
+		}
+
+		if msg := applyRule(value, name, param); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+var (
+	e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	// Firestore reserves IDs matching __.*__ and does not allow slashes
+	reservedDocID = regexp.MustCompile(`^__.*__$`)
+)
+
+func applyRule(value reflect.Value, name, param string) string {
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validation: invalid %s parameter %q", name, param))
+		}
+		n, isLength := measure(value)
+		if name == "min" && n < limit {
+			if isLength {
+				return fmt.Sprintf("must be at least %s characters", param)
+			}
+			return fmt.Sprintf("must be at least %s", param)
+		}
+		if name == "max" && n > limit {
+			if isLength {
+				return fmt.Sprintf("must be at most %s characters", param)
+			}
+			return fmt.Sprintf("must be at most %s", param)
+		}
+	case "oneof":
+		options := strings.Fields(param)
+		s := fmt.Sprint(value.Interface())
+		if indexOf(options, s) < 0 {
+			return "must be one of: " + strings.Join(options, ", ")
+		}
+	case "email":
+		addr, err := mail.ParseAddress(value.String())
+		if err != nil || addr.Address != value.String() {
+			return "must be a valid email address"
+		}
+	case "e164":
+		if !e164Pattern.MatchString(value.String()) {
+			return "must be a phone number in E.164 format, e.g. +6281234567890"
+		}
+	case "url":
+		u, err := url.Parse(value.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	case "rfc3339":
+		if _, err := time.Parse(time.RFC3339, value.String()); err != nil {
+			return "must be an RFC 3339 timestamp, e.g. 2025-07-22T14:00:00+07:00"
+		}
+	case "docid":
+		s := value.String()
+		if strings.Contains(s, "/") || s == "." || s == ".." || reservedDocID.MatchString(s) || len(s) > 1500 {
+			return "must not contain '/' or be a reserved ID"
+		}
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q", name))
+	}
+	return ""
+}
+
+// measure returns the number compared by min/max and whether it is a length
+func measure(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false
+	default:
+		panic(fmt.Sprintf("validation: min/max not supported on %s", value.Kind()))
+	}
+}
+
+func isBlank(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if strings.TrimSpace(item) == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,68 +1,329 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"pawtroli-be/internal/api"
+	"pawtroli-be/internal/apperror"
+	"pawtroli-be/internal/certreload"
 	"pawtroli-be/internal/firebase"
 	"pawtroli-be/internal/logger"
+	"pawtroli-be/internal/metrics"
 	"pawtroli-be/internal/middleware"
+	"pawtroli-be/internal/ratelimit"
 	"pawtroli-be/internal/services"
+	"pawtroli-be/internal/tracing"
 
 	"github.com/gorilla/mux"
 )
 
+// envMegabytes reads a size in megabytes from the environment, returning bytes
+func envMegabytes(name string, def int64) int64 {
+	mb, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil {
+		mb = def
+	}
+	return mb << 20
+}
+
+// metricsAuth requires the bearer token on requests to next unless token is empty
+func metricsAuth(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			apperror.Write(w, r, apperror.Unauthorized("Invalid metrics token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// envList reads a comma separated list from the environment
+func envList(name string, def []string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// corsFromEnv reads the CORS configuration. Cross-origin requests are refused unless
+// CORS_ALLOWED_ORIGINS lists the web clients, e.g. https://dashboard.pawtroli.com.
+func corsFromEnv() middleware.CORS {
+	maxAge, err := strconv.Atoi(os.Getenv("CORS_MAX_AGE_SECONDS"))
+	if err != nil {
+		maxAge = 600
+	}
+	return middleware.CORS{
+		AllowedOrigins:   envList("CORS_ALLOWED_ORIGINS", nil),
+		AllowedMethods:   envList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+		AllowedHeaders:   envList("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "Idempotency-Key", middleware.RequestIDHeader}),
+		ExposedHeaders:   []string{middleware.RequestIDHeader, "Retry-After", "Idempotent-Replayed", "Location", "Deprecation", "Sunset", "Link"},
+		AllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") == "true",
+		MaxAge:           time.Duration(maxAge) * time.Second,
+	}
+}
+
+// envLevel reads a log level from the environment
+func envLevel(name string, def logger.LogLevel) logger.LogLevel {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	level, err := logger.ParseLevel(value)
+	if err != nil {
+		logger.LogWarningf("Ignoring %s: %v", name, err)
+		return def
+	}
+	return level
+}
+
+// configureLogSinks sets up the log destinations from the environment. Console and file
+// are always enabled; syslog and the remote collector are enabled by their address.
+func configureLogSinks() {
+	sinks := []logger.Sink{
+		logger.NewConsoleSink(envLevel("LOG_LEVEL", logger.DEBUG), os.Getenv("LOG_COLOR") == "true"),
+		logger.FileSink(envLevel("LOG_FILE_LEVEL", logger.DEBUG)),
+	}
+
+	// LOG_SYSLOG is "local" for the local daemon or "udp://host:514" / "tcp://host:514"
+	if addr := os.Getenv("LOG_SYSLOG"); addr != "" {
+		network, address, _ := strings.Cut(addr, "://")
+		if addr == "local" {
+			network, address = "", ""
+		}
+		syslogSink, err := logger.NewSyslogSink(envLevel("LOG_SYSLOG_LEVEL", logger.INFO), network, address, "pawtroli-be")
+		if err != nil {
+			logger.LogWarningf("Syslog unavailable, not forwarding logs: %v", err)
+		} else {
+			sinks = append(sinks, syslogSink)
+		}
+	}
+
+	if url := os.Getenv("LOG_COLLECTOR_URL"); url != "" {
+		httpSink, err := logger.NewHTTPSink(logger.HTTPSinkConfig{
+			URL:       url,
+			MinLevel:  envLevel("LOG_COLLECTOR_LEVEL", logger.INFO),
+			Service:   "pawtroli-be",
+			BufferDir: filepath.Join("logs", "collector"),
+		})
+		if err != nil {
+			logger.LogWarningf("Log collector unavailable, not shipping logs: %v", err)
+		} else {
+			sinks = append(sinks, httpSink)
+		}
+	}
+
+	logger.SetSinks(sinks...)
+}
+
 func main() {
 	// Initialize logger first
+	logger.SetMaxFileSize(envMegabytes("LOG_MAX_FILE_SIZE_MB", 100))
 	if err := logger.InitLogger(); err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
 	defer logger.CloseLogger()
+	configureLogSinks()
 
 	// Initialize log rotation service
-	// Keep logs for 30 days, maximum 50 files
+	// Keep logs for 30 days, maximum 50 files and 1 GB in total by default
 	logRotationService := services.NewLogRotationService("logs", 50, 30*24*time.Hour)
+	logRotationService.SetMaxTotalSize(envMegabytes("LOG_MAX_TOTAL_SIZE_MB", 1024))
 	logRotationService.Start()
 	defer logRotationService.Stop()
 
-	// Setup graceful shutdown
+	// Setup graceful shutdown; signals received during startup stop the server once it runs
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		logger.LogInfo("Shutting down server...")
-		logRotationService.Stop()
-		logger.CloseLogger()
-		os.Exit(0)
-	}()
 
 	logger.LogInfo("Starting Pawtroli Backend Server...")
 
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.ConfigFromEnv())
+	if err != nil {
+		logger.LogWarningf("Tracing disabled: %v", err)
+	} else {
+		defer shutdownTracing(context.Background())
+	}
+
 	firebase.InitFirebase()
 	api.InitHandlers()
 
 	// Pass log rotation service to API handlers
 	api.SetLogRotationService(logRotationService)
 
+	api.SetAuditService(services.NewAuditService(api.FirestoreClient()))
+
+	// Responses to requests with an Idempotency-Key are replayed to retries for 24 hours
+	api.SetIdempotencyService(services.NewIdempotencyService(services.NewFirestoreIdempotencyStore(api.FirestoreClient()), 24*time.Hour, time.Minute))
+
+	// Push notifications go through FCM, falling back to logging them
+	// when messaging is unavailable (e.g. local development)
+	var sender services.NotificationSender
+	fcmSender, err := services.NewFCMSender(context.Background(), firebase.App)
+	if err != nil {
+		logger.LogWarningf("FCM unavailable, notifications will not be delivered: %v", err)
+		sender = services.LogSender{}
+	} else {
+		sender = fcmSender
+	}
+	api.SetNotificationService(services.NewNotificationService(api.FirestoreClient(), sender))
+
+	// Side effects such as notifications and emails run as background jobs
+	jobQueue := services.NewJobQueue(services.NewFirestoreJobStore(api.FirestoreClient()), services.DefaultJobQueueConfig())
+	api.SetJobQueue(jobQueue)
+
+	// Email is only enabled when an SMTP server is configured
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			smtpPort = 587
+		}
+		smtpSender := services.NewSMTPSender(services.SMTPConfig{
+			Host:     smtpHost,
+			Port:     smtpPort,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+		emailService, err := services.NewEmailService(api.FirestoreClient(), smtpSender)
+		if err != nil {
+			logger.LogErrorf("Failed to initialize email service: %v", err)
+			panic(err)
+		}
+		emailService.Start()
+		defer emailService.Stop()
+		api.SetEmailService(emailService)
+	} else {
+		logger.LogWarning("SMTP_HOST not set, email notifications are disabled")
+	}
+
+	// Cascading deletes also remove uploaded media when a storage bucket is configured
+	var media services.MediaDeleter = services.NoopMediaDeleter{}
+	if bucket := os.Getenv("FIREBASE_STORAGE_BUCKET"); bucket != "" {
+		storageDeleter, err := services.NewStorageMediaDeleter(context.Background(), firebase.App, bucket)
+		if err != nil {
+			logger.LogWarningf("Storage unavailable, media will not be deleted: %v", err)
+		} else {
+			media = storageDeleter
+		}
+	} else {
+		logger.LogWarning("FIREBASE_STORAGE_BUCKET not set, media will not be deleted")
+	}
+	api.SetDeletionService(services.NewDeletionService(api.FirestoreClient(), media))
+
+	jobQueue.Start()
+	defer jobQueue.Stop()
+
 	r := mux.NewRouter()
+	r.NotFoundHandler = middleware.RequestIDMiddleware(apperror.NotFoundHandler())
+	r.MethodNotAllowedHandler = middleware.RequestIDMiddleware(apperror.MethodNotAllowedHandler())
+
+	// X-Forwarded-For is only trusted from the load balancer, e.g. TRUSTED_PROXIES=35.191.0.0/16,130.211.0.0/22
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := middleware.SetTrustedProxies(strings.Split(proxies, ",")); err != nil {
+			logger.LogErrorf("Failed to parse TRUSTED_PROXIES: %v", err)
+			panic(err)
+		}
+	}
+	middleware.SetRateLimiter(middleware.NewRateLimiter(ratelimit.NewMemoryStore(10*time.Minute), api.RateLimits))
 
-	// Add logging middleware to all routes
+	// Add request ID, tracing, deadline, logging and rate limiting middleware to all routes
+	r.Use(middleware.RequestIDMiddleware)
+	r.Use(middleware.TracingMiddleware)
+	r.Use(middleware.DeadlineMiddleware(api.RequestDeadlines))
 	r.Use(middleware.LoggingMiddleware)
+	r.Use(middleware.RateLimitMiddleware)
 
-	// Routes
-	api.UserRoutes(r)
-	api.PetRoutes(r)
-	api.ChatRoutes(r)
-	api.AdminRoutes(r)
+	// Prometheus scrape endpoint, protected by a bearer token when METRICS_TOKEN is set
+	r.Handle("/metrics", metricsAuth(os.Getenv("METRICS_TOKEN"), metrics.Handler())).Methods("GET")
 
-	logger.LogInfo("🚀 Server running on :8080")
-	err := http.ListenAndServe("0.0.0.0:8080", r)
-	if err != nil {
+	// Routes, under /v1 and as deprecated unversioned aliases
+	api.MountRoutes(r, api.UserRoutes, api.PetRoutes, api.ChatRoutes, api.AdminRoutes, api.NotificationRoutes)
+
+	// OpenAPI document and docs UI; routes missing from it are logged and left out
+	if err := api.DocsRoutes(r); err != nil {
+		logger.LogErrorf("Failed to document routes: %v", err)
+	}
+
+	// CORS and the security headers wrap the router so they also apply to preflight
+	// requests and unknown routes
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	useTLS := certFile != "" && keyFile != ""
+	var handler http.Handler = r
+	handler = middleware.CORSMiddleware(corsFromEnv())(handler)
+	handler = middleware.SecurityHeadersMiddleware(useTLS || os.Getenv("HSTS") == "true")(handler)
+
+	addr := os.Getenv("LISTEN_ADDR")
+	if addr == "" {
+		addr = "0.0.0.0:8080"
+	}
+	// No write timeout, since log downloads and tails stream for as long as they need
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		Protocols:         new(http.Protocols),
+	}
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetHTTP2(true)
+	// Cleartext HTTP/2 (h2c) is for load balancers that speak HTTP/2 to the backend
+	if os.Getenv("HTTP2_CLEARTEXT") == "true" {
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	// Stop accepting requests on a signal and let main return, so that the deferred
+	// shutdown of the job queue, email, tracing and logging runs
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-c
+		logger.LogInfo("Shutting down server...")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.LogWarningf("Server did not shut down cleanly: %v", err)
+		}
+	}()
+
+	if useTLS {
+		certs, err := certreload.NewReloader(certFile, keyFile, time.Minute)
+		if err != nil {
+			logger.LogErrorf("Failed to load TLS certificate: %v", err)
+			panic(err)
+		}
+		certs.Start()
+		defer certs.Stop()
+		server.TLSConfig = certs.TLSConfig()
+
+		logger.LogInfof("🚀 Server running on https://%s", addr)
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		logger.LogInfof("🚀 Server running on http://%s", addr)
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		logger.LogErrorf("Server failed: %v", err)
 		return
 	}
+	<-shutdownDone
+	logger.LogInfo("Server stopped")
 }